@@ -1,76 +1,93 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"MyOwnHTTP/internal/headers"
 	"MyOwnHTTP/internal/request"
 	"MyOwnHTTP/internal/response"
+	"MyOwnHTTP/internal/router"
 	"MyOwnHTTP/internal/server"
+	"MyOwnHTTP/internal/static"
 )
 
 const port = 42069
 
+// shutdownTimeout is how long in-flight requests get to finish once the
+// server is asked to stop.
+const shutdownTimeout = 10 * time.Second
+
+// compressMinSize is the smallest response body worth compressing.
+const compressMinSize = 1024
+
 func main() {
-	server, err := server.Serve(port, handler)
+	handler := server.Chain(newRouter().Handler(), server.Recover, server.RequestIDs, server.Logging, server.Compress(compressMinSize), server.Decompress(request.DecodeLimits{}))
+	server, err := server.ServeWithOptions(handler, server.Options{Addr: fmt.Sprintf(":%d", port)})
 	if err != nil {
 		log.Fatalf("Error starting server: %v", err)
 	}
-	defer server.Close()
-	log.Println("Server started on port", port)
+	log.Println("Server started on", server.Addr())
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
-	log.Println("Server gracefully stopped")
-}
 
-func handler(w *response.Writer, req *request.Request) {
-	if req.RequestLine.RequestTarget == "/yourproblem" {
-		handler400(w, req)
-		return
-	}
-	if req.RequestLine.RequestTarget == "/myproblem" {
-		handler500(w, req)
-		return
-	}
-	if req.RequestLine.RequestTarget == "/video" {
-		handlerVideo(w, req)
-		return
-	}
-	if strings.HasPrefix(req.RequestLine.RequestTarget, "/httpbin") {
-		handlerHttpBin(w, req)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	dropped, err := server.Shutdown(ctx)
+	if err != nil {
+		log.Printf("Server stopped with %d connections dropped: %v\n", dropped, err)
 		return
 	}
-	handler200(w, req)
-	return
+	log.Println("Server gracefully stopped")
+}
+
+func newRouter() *router.Router {
+	r := router.New()
+	r.Get("/", handler200)
+	r.Get("/yourproblem", server.HandleErrors(handler400))
+	r.Get("/myproblem", server.HandleErrors(handler500))
+	assets := static.New("assets")
+	r.Get("/video", assets.File("vim.mp4"))
+	r.Get("/assets/*", assets.Handler())
+	r.Get("/httpbin/*", server.HandleErrors(handlerHttpBin))
+	r.Post("/upload", server.HandleErrors(handlerUpload))
+	return r
 }
 
-func handlerHttpBin(w *response.Writer, req *request.Request) {
+func handlerHttpBin(w *response.Writer, req *request.Request) *server.HandlerError {
 	h := response.GetDefaultHeaders(0)
-	h.Remove("Content-Length")
-	h.Override("Transfer-Encoding", "chunked")
-	h["Trailer"] = "X-Content-SHA256, X-Content-Length"
+	h.Del("Content-Length")
+	h.Set("Transfer-Encoding", "chunked")
+	h.Set("Trailer", "X-Content-SHA256, X-Content-Length")
 
 	trailers := headers.NewHeaders()
 
-	urlTarget := strings.TrimPrefix(req.RequestLine.RequestTarget, "/httpbin")
-	url := fmt.Sprintf("%s%s", "https://httpbin.org", urlTarget)
+	url := fmt.Sprintf("%s/%s", "https://httpbin.org", req.Param("*"))
+	if req.URL.RawQuery != "" {
+		url += "?" + req.URL.RawQuery
+	}
 	log.Printf("The URL for the request is:  %v\n", url)
 	extResponse, err := http.Get(url)
 	var finalBody []byte
 	if err != nil {
-		body := fmt.Appendf([]byte{}, "Failed to get request from httpbin: %v", err)
-		writeErrorBody(w, h, body)
-		return
+		return &server.HandlerError{
+			StatusCode: response.StatusBadGateway,
+			Message:    fmt.Sprintf("Failed to get request from httpbin: %v", err),
+		}
 	}
 	w.WriteStatusLine(response.StatusOK)
 	w.WriteHeaders(h)
@@ -92,77 +109,61 @@ func handlerHttpBin(w *response.Writer, req *request.Request) {
 		}
 	}
 	hash := sha256.Sum256(finalBody)
-	trailers["X-Content-SHA256"] = fmt.Sprintf("%x", hash)
-	trailers["X-Content-Length"] = strconv.Itoa(len(finalBody))
+	trailers.Set("X-Content-SHA256", fmt.Sprintf("%x", hash))
+	trailers.Set("X-Content-Length", strconv.Itoa(len(finalBody)))
 	w.WriteChunkedBodyDone(trailers)
-	return
+	return nil
 }
 
-func handlerVideo(w *response.Writer, req *request.Request) {
-	file, err := os.ReadFile("assets/vim.mp4")
-	if err != nil {
-		h := headers.NewHeaders()
-		w.WriteStatusLine(response.StatusBadRequest)
-		writeErrorBody(w, h, []byte(fmt.Sprintf("Failed to read file: %v", err)))
-		return
+// handlerUpload lists the fields and files of a submitted form.
+func handlerUpload(w *response.Writer, req *request.Request) *server.HandlerError {
+	if err := req.ParseForm(); err != nil {
+		statusCode := response.StatusBadRequest
+		switch {
+		case errors.Is(err, request.ErrFormTooLarge):
+			statusCode = response.StatusContentTooLarge
+		case errors.Is(err, request.ErrNotForm):
+			statusCode = response.StatusUnsupportedMediaType
+		}
+		return &server.HandlerError{
+			StatusCode: statusCode,
+			Message:    fmt.Sprintf("Failed to parse the form: %v", err),
+		}
 	}
-	length := strconv.Itoa(len(file))
-	fmt.Printf("The length of the body is: %s\n", length)
 
-	h := response.GetDefaultHeaders(len(file))
-	h["Content-Type"] = "video/mp4"
-	w.WriteStatusLine(response.StatusOK)
-	err = w.WriteHeaders(h)
-	if err != nil {
-		log.Printf("Failed to write the headers to the buffer: %v\n", err)
+	var body strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(req.Form)) {
+		for _, value := range req.Form[key] {
+			fmt.Fprintf(&body, "field %s: %q\n", key, value)
+		}
 	}
-	w.WriteBody(file)
-	return
-}
-
-func writeErrorBody(w *response.Writer, h headers.Headers, message []byte) {
-	w.WriteStatusLine(response.StatusBadRequest)
-	h["Content-Length"] = strconv.Itoa(len(message))
+	if req.MultipartForm != nil {
+		for _, key := range slices.Sorted(maps.Keys(req.MultipartForm.File)) {
+			for _, file := range req.MultipartForm.File[key] {
+				fmt.Fprintf(&body, "file %s: %q, %s, %d bytes\n", key, file.Filename, file.ContentType, file.Size)
+			}
+		}
+	}
+	h := response.GetDefaultHeaders(body.Len())
+	h.Set("Content-Type", "text/plain")
+	w.WriteStatusLine(response.StatusOK)
 	w.WriteHeaders(h)
-	w.WriteBody(message)
-	return
+	w.WriteBody([]byte(body.String()))
+	return nil
 }
 
-func handler400(w *response.Writer, _ *request.Request) {
-	w.WriteStatusLine(response.StatusBadRequest)
-	body := []byte(`<html>
-<head>
-<title>400 Bad Request</title>
-</head>
-<body>
-<h1>Bad Request</h1>
-<p>Your request honestly kinda sucked.</p>
-</body>
-</html>
-`)
-	h := response.GetDefaultHeaders(len(body))
-	h.Override("Content-Type", "text/html")
-	w.WriteHeaders(h)
-	w.WriteBody(body)
-	return
+func handler400(_ *response.Writer, _ *request.Request) *server.HandlerError {
+	return &server.HandlerError{
+		StatusCode: response.StatusBadRequest,
+		Message:    "Your request honestly kinda sucked.",
+	}
 }
 
-func handler500(w *response.Writer, _ *request.Request) {
-	w.WriteStatusLine(response.StatusInternalServerError)
-	body := []byte(`<html>
-<head>
-<title>500 Internal Server Error</title>
-</head>
-<body>
-<h1>Internal Server Error</h1>
-<p>Okay, you know what? This one is on me.</p>
-</body>
-</html>
-`)
-	h := response.GetDefaultHeaders(len(body))
-	h.Override("Content-Type", "text/html")
-	w.WriteHeaders(h)
-	w.WriteBody(body)
+func handler500(_ *response.Writer, _ *request.Request) *server.HandlerError {
+	return &server.HandlerError{
+		StatusCode: response.StatusInternalServerError,
+		Message:    "Okay, you know what? This one is on me.",
+	}
 }
 
 func handler200(w *response.Writer, _ *request.Request) {
@@ -178,7 +179,7 @@ func handler200(w *response.Writer, _ *request.Request) {
 </html>
 `)
 	h := response.GetDefaultHeaders(len(body))
-	h.Override("Content-Type", "text/html")
+	h.Set("Content-Type", "text/html")
 	w.WriteHeaders(h)
 	w.WriteBody(body)
 	return
@@ -3,29 +3,129 @@ package headers
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"iter"
 	"slices"
 	"strings"
-	"unicode"
 )
 
-type Headers map[string]string
+// Headers is an ordered list of header fields. Names match without
+// regard to case and are kept in canonical form, like Content-Type. A
+// name may appear more than once, as Set-Cookie does, and fields are
+// written out in the order they were added.
+type Headers struct {
+	fields []field
+}
+
+type field struct {
+	name  string
+	value string
+}
 
 const crlf = "\r\n"
 
-func NewHeaders() Headers {
-	return map[string]string{}
+func NewHeaders() *Headers {
+	return &Headers{}
 }
 
-func (h Headers) Get(key string) (string, error) {
-	key = strings.ToLower(key)
-	if value, exists := h[key]; exists {
-		return value, nil
-	} else {
+// Get returns the values of a header joined with ", ", which is how a
+// list-valued header repeated over several lines reads as one.
+func (h *Headers) Get(key string) (string, error) {
+	values := h.Values(key)
+	if len(values) == 0 {
 		return "", fmt.Errorf("Value not found")
 	}
+	return strings.Join(values, ", "), nil
+}
+
+// Has reports whether the header is present at all.
+func (h *Headers) Has(key string) bool {
+	return h.index(key) != -1
+}
+
+// Values returns every value of a header in order.
+func (h *Headers) Values(key string) []string {
+	var values []string
+	for _, f := range h.list() {
+		if strings.EqualFold(f.name, key) {
+			values = append(values, f.value)
+		}
+	}
+	return values
+}
+
+// Add appends a value, keeping any the header already has.
+func (h *Headers) Add(key, value string) {
+	h.fields = append(h.fields, field{name: CanonicalName(key), value: value})
+}
+
+// Set replaces every value of a header with one. The header keeps its
+// place if it was already there.
+func (h *Headers) Set(key, value string) {
+	i := h.index(key)
+	if i == -1 {
+		h.Add(key, value)
+		return
+	}
+	h.fields[i].value = value
+	rest := slices.DeleteFunc(h.fields[i+1:], func(f field) bool {
+		return strings.EqualFold(f.name, key)
+	})
+	h.fields = h.fields[:i+1+len(rest)]
+}
+
+// Del removes every value of a header.
+func (h *Headers) Del(key string) {
+	h.fields = slices.DeleteFunc(h.fields, func(f field) bool {
+		return strings.EqualFold(f.name, key)
+	})
+}
+
+// Len is the number of fields, counting repeated names separately.
+func (h *Headers) Len() int {
+	return len(h.list())
+}
+
+// All yields every field in order with its canonical name.
+func (h *Headers) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, f := range h.list() {
+			if !yield(f.name, f.value) {
+				return
+			}
+		}
+	}
+}
+
+// WriteTo writes the fields as "Name: value" lines. The blank line that
+// ends a header section is left to the caller.
+func (h *Headers) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	for _, f := range h.list() {
+		buf.WriteString(f.name)
+		buf.WriteString(": ")
+		buf.WriteString(f.value)
+		buf.WriteString(crlf)
+	}
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// list lets the read-only methods treat a nil *Headers as empty.
+func (h *Headers) list() []field {
+	if h == nil {
+		return nil
+	}
+	return h.fields
+}
+
+func (h *Headers) index(key string) int {
+	return slices.IndexFunc(h.list(), func(f field) bool {
+		return strings.EqualFold(f.name, key)
+	})
 }
 
-func (h Headers) Parse(data []byte) (n int, done bool, err error) {
+func (h *Headers) Parse(data []byte) (n int, done bool, err error) {
 	idx := bytes.Index(data, []byte(crlf))
 	if idx == -1 {
 		return 0, false, nil
@@ -34,42 +134,96 @@ func (h Headers) Parse(data []byte) (n int, done bool, err error) {
 		return 2, true, nil
 	}
 	dataString := string(data[:idx])
+	if dataString[0] == ' ' || dataString[0] == '\t' {
+		if err := h.unfold(dataString); err != nil {
+			return 0, false, err
+		}
+		return idx + 2, false, nil
+	}
 	dataSplits := strings.Split(dataString, ":")
 	if len(dataSplits) < 2 {
-		return 0, false, fmt.Errorf("Improperly formatted header %s\n", dataString)
+		return 0, false, fmt.Errorf("Improperly formatted header %s", dataString)
 	}
+	// no whitespace is allowed around the name, RFC 9112 section 5.1, and
+	// isKeyValid turns away spaces and tabs along with the rest
 	key := dataSplits[0]
-	if len(key) != len(strings.TrimRight(key, " ")) {
-		return 0, false, fmt.Errorf("Invalid key formatting: %s\n", key)
+	if key == "" {
+		return 0, false, fmt.Errorf("Empty header name")
 	}
-	key = strings.TrimSpace(key)
 	if !isKeyValid(key) {
-		return 0, false, fmt.Errorf("Invalid character in key: %s\n", key)
+		return 0, false, fmt.Errorf("Invalid character in key: %s", key)
 	}
-	key = strings.ToLower(key)
 	value := strings.Join(dataSplits[1:], ":")
-	value = strings.TrimSpace(value)
-
-	if _, exists := h[key]; exists {
-		h[key] += ", " + value
-	} else {
-		h[key] = value
+	value = strings.Trim(value, " \t")
+	if !isValueValid(value) {
+		return 0, false, fmt.Errorf("Invalid character in value of %s: %q", key, value)
 	}
+
+	h.Add(key, value)
 	return idx + 2, false, nil
 }
 
-func (h Headers) Override(key, value string) {
-	key = strings.ToLower(key)
-	h[key] = value
+// unfold handles an obs-fold line, a deprecated continuation of the
+// previous field's value, by joining it on with a space as RFC 9112
+// allows.
+func (h *Headers) unfold(line string) error {
+	if len(h.fields) == 0 {
+		return fmt.Errorf("Folded line without a header to continue: %q", line)
+	}
+	value := strings.Trim(line, " \t")
+	if !isValueValid(value) {
+		return fmt.Errorf("Invalid character in folded value: %q", value)
+	}
+	last := &h.fields[len(h.fields)-1]
+	if last.value == "" {
+		last.value = value
+	} else if value != "" {
+		last.value += " " + value
+	}
+	return nil
 }
 
-func (h Headers) Remove(key string) {
-	delete(h, key)
+// CanonicalName upper-cases the first letter of a header name and every
+// letter after a hyphen, lower-casing the rest: content-type becomes
+// Content-Type. Names that aren't valid tokens are returned as is.
+func CanonicalName(name string) string {
+	if name == "" || !isKeyValid(name) {
+		return name
+	}
+	b := []byte(name)
+	upper := true
+	for i, c := range b {
+		switch {
+		case upper && 'a' <= c && c <= 'z':
+			b[i] = c - ('a' - 'A')
+		case !upper && 'A' <= c && c <= 'Z':
+			b[i] = c + ('a' - 'A')
+		}
+		upper = c == '-'
+	}
+	return string(b)
 }
 
+// isKeyValid reports whether s is a token: ASCII letters, digits and the
+// tchar specials only.
 func isKeyValid(s string) bool {
 	for _, ch := range s {
-		if !unicode.IsLetter(ch) && !unicode.IsDigit(ch) && !isSpecial(ch) {
+		isAlnum := ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ('0' <= ch && ch <= '9')
+		if !isAlnum && !isSpecial(ch) {
+			return false
+		}
+	}
+	return true
+}
+
+// isValueValid allows visible characters, spaces, tabs and obs-text.
+// CR, LF and NUL are what smuggling attempts use to sneak in another
+// header or end the section early, and no other control is allowed
+// either.
+func isValueValid(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < ' ' && c != '\t') || c == 0x7f {
 			return false
 		}
 	}
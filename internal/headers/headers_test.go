@@ -1,6 +1,7 @@
 package headers
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -14,7 +15,7 @@ func TestHeadersParse(t *testing.T) {
 	n, done, err := headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "localhost:42069", headers["host"])
+	assert.Equal(t, []string{"localhost:42069"}, headers.Values("host"))
 	assert.Equal(t, 23, n)
 	assert.False(t, done)
 
@@ -36,8 +37,8 @@ func TestHeadersParse(t *testing.T) {
 	n, done, err = headers.Parse(data[n:])
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "localhost:42069", headers["host"])
-	assert.Equal(t, "application/json", headers["content-type"])
+	assert.Equal(t, []string{"localhost:42069"}, headers.Values("host"))
+	assert.Equal(t, []string{"application/json"}, headers.Values("content-type"))
 	assert.False(t, done)
 
 	// Test: Valid header with multiple values
@@ -51,11 +52,82 @@ func TestHeadersParse(t *testing.T) {
 	n, done, err = headers.Parse(data[readTillIndex:])
 	require.NotNil(t, headers)
 	require.NoError(t, err)
-	assert.Equal(t, "lane-loves-go, prime-loves-zig, tj-loves-ocaml", headers["set-person"])
+	assert.Equal(t, []string{"lane-loves-go", "prime-loves-zig", "tj-loves-ocaml"}, headers.Values("set-person"))
 
 	// Test: Invalid header character
 	headers = NewHeaders()
 	data = []byte("H©st: localhost:42069\r\n\r\n")
 	n, done, err = headers.Parse(data)
 	require.Error(t, err)
+
+	// Test: Tab before the colon
+	headers = NewHeaders()
+	_, _, err = headers.Parse([]byte("Host\t: localhost:42069\r\n\r\n"))
+	require.Error(t, err)
+}
+
+func TestHeadersOrderAndValues(t *testing.T) {
+	h := NewHeaders()
+	h.Add("content-type", "text/plain")
+	h.Add("SET-COOKIE", "a=1")
+	h.Add("x-request-id", "abc")
+	h.Add("Set-Cookie", "b=2")
+
+	// Test: Names match regardless of case
+	assert.Equal(t, []string{"a=1", "b=2"}, h.Values("set-cookie"))
+	value, err := h.Get("Content-Type")
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", value)
+	assert.True(t, h.Has("X-REQUEST-ID"))
+
+	// Test: Get joins repeated values
+	value, err = h.Get("set-cookie")
+	require.NoError(t, err)
+	assert.Equal(t, "a=1, b=2", value)
+
+	// Test: Serialization is canonical and keeps insertion order
+	var buf bytes.Buffer
+	_, err = h.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, "Content-Type: text/plain\r\nSet-Cookie: a=1\r\nX-Request-Id: abc\r\nSet-Cookie: b=2\r\n", buf.String())
+
+	// Test: Set replaces every value in place of the first
+	h.Set("set-cookie", "c=3")
+	assert.Equal(t, []string{"c=3"}, h.Values("Set-Cookie"))
+	var names []string
+	for name := range h.All() {
+		names = append(names, name)
+	}
+	assert.Equal(t, []string{"Content-Type", "Set-Cookie", "X-Request-Id"}, names)
+
+	// Test: Set adds missing headers at the end
+	h.Set("Vary", "Accept")
+	assert.Equal(t, 4, h.Len())
+
+	// Test: Del removes every value
+	h.Add("set-cookie", "d=4")
+	h.Del("Set-Cookie")
+	assert.False(t, h.Has("set-cookie"))
+	_, err = h.Get("set-cookie")
+	assert.Error(t, err)
+	assert.Equal(t, 3, h.Len())
+
+	// Test: A nil header list reads as empty
+	var empty *Headers
+	assert.False(t, empty.Has("host"))
+	assert.Equal(t, 0, empty.Len())
+}
+
+func TestCanonicalName(t *testing.T) {
+	tests := map[string]string{
+		"content-type":     "Content-Type",
+		"CONTENT-LENGTH":   "Content-Length",
+		"x-content-sha256": "X-Content-Sha256",
+		"etag":             "Etag",
+		"www-authenticate": "Www-Authenticate",
+		"bad name":         "bad name",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, CanonicalName(in), in)
+	}
 }
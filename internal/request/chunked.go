@@ -0,0 +1,63 @@
+package request
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func (r *Request) parseChunkSize(data []byte) (int, error) {
+	idx := bytes.Index(data, []byte(crlf))
+	if idx == -1 {
+		if len(data) > maxChunkSizeLineBytes {
+			return 0, fmt.Errorf("%w: chunk size line too long", ErrBodyLengthMismatch)
+		}
+		return 0, nil
+	}
+	line := string(data[:idx])
+	// chunk extensions are allowed after a ';' and we have no use for them
+	sizeText, _, _ := strings.Cut(line, ";")
+	sizeText = strings.TrimRight(sizeText, " \t")
+	if sizeText == "" {
+		return 0, fmt.Errorf("%w: missing chunk size: %q", ErrBodyLengthMismatch, line)
+	}
+	size, err := strconv.ParseUint(sizeText, 16, 62)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid chunk size: %q", ErrBodyLengthMismatch, line)
+	}
+	if err := r.checkBodySize(int64(r.bodyRead) + int64(size)); err != nil {
+		return 0, err
+	}
+	if size == 0 {
+		r.state = requestStateParsingTrailers
+	} else {
+		r.chunkRemaining = int(size)
+		r.state = requestStateParsingChunkData
+	}
+	return idx + 2, nil
+}
+
+func (r *Request) parseChunkData(data []byte) (int, error) {
+	if len(data) > r.chunkRemaining {
+		data = data[:r.chunkRemaining]
+	}
+	r.Body = append(r.Body, data...)
+	r.bodyRead += len(data)
+	r.chunkRemaining -= len(data)
+	if r.chunkRemaining == 0 {
+		r.state = requestStateParsingChunkDataEnd
+	}
+	return len(data), nil
+}
+
+func (r *Request) parseChunkDataEnd(data []byte) (int, error) {
+	if len(data) < 2 {
+		return 0, nil
+	}
+	if string(data[:2]) != crlf {
+		return 0, fmt.Errorf("%w: chunk data not followed by CRLF", ErrBodyLengthMismatch)
+	}
+	r.state = requestStateParsingChunkSize
+	return 2, nil
+}
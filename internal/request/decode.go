@@ -0,0 +1,175 @@
+package request
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Errors from DecodeBody. ErrUnsupportedEncoding is worth a 415,
+// ErrDecodedBodyTooLarge a 413 and ErrCorruptEncoding a 400.
+var (
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+	ErrDecodedBodyTooLarge = errors.New("decompressed body too large")
+	ErrCorruptEncoding     = errors.New("corrupt compressed body")
+)
+
+// DecodableEncodings are the content codings DecodeBody understands.
+var DecodableEncodings = []string{"gzip", "deflate"}
+
+// DecodeLimits guards against decompression bombs, small bodies that
+// expand to something huge. Zero fields use the defaults below.
+type DecodeLimits struct {
+	// MaxBytes caps the size of the decompressed body.
+	MaxBytes int64
+	// MaxRatio caps how many times larger than the compressed body the
+	// decompressed one may get. It isn't applied to the first
+	// ratioAllowance bytes, as tiny bodies can compress very well.
+	MaxRatio int64
+}
+
+const (
+	defaultDecodeMaxBytes = 32 << 20
+	defaultDecodeMaxRatio = 100
+	ratioAllowance        = 64 << 10
+)
+
+// DecodeBody undoes the Content-Encoding of the body, so Body or
+// BodyReader hold what the client compressed. A buffered body is decoded
+// straight away, a streaming one as it is read. Content-Encoding is
+// removed afterwards, and Content-Length too unless the decoded length
+// is known.
+func (r *Request) DecodeBody(limits DecodeLimits) error {
+	codings, err := r.contentCodings()
+	if err != nil || len(codings) == 0 {
+		return err
+	}
+	if limits.MaxBytes <= 0 {
+		limits.MaxBytes = defaultDecodeMaxBytes
+	}
+	if limits.MaxRatio <= 0 {
+		limits.MaxRatio = defaultDecodeMaxRatio
+	}
+
+	decoded := &decodedBody{
+		compressed: &countingReader{r: r.body()},
+		limits:     limits,
+		codings:    codings,
+	}
+	r.Headers.Del("Content-Encoding")
+	r.Headers.Del("Content-Length")
+	if r.BodyReader != nil {
+		decoded.closer = r.BodyReader
+		r.BodyReader = decoded
+		return nil
+	}
+	body, err := io.ReadAll(decoded)
+	if err != nil {
+		return err
+	}
+	r.Body = body
+	r.Headers.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// contentCodings lists the codings to undo, last applied first, and
+// checks we can undo all of them.
+func (r *Request) contentCodings() ([]string, error) {
+	var codings []string
+	for _, value := range r.Headers.Values("Content-Encoding") {
+		for coding := range strings.SplitSeq(value, ",") {
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			switch coding {
+			case "", "identity":
+				continue
+			case "x-gzip":
+				coding = "gzip"
+			}
+			if !slices.Contains(DecodableEncodings, coding) {
+				return nil, fmt.Errorf("%w: %q", ErrUnsupportedEncoding, coding)
+			}
+			codings = append(codings, coding)
+		}
+	}
+	slices.Reverse(codings)
+	return codings, nil
+}
+
+// decodedBody reads a body through its decompressors, which are only set
+// up on the first read since they start by reading a header.
+type decodedBody struct {
+	compressed *countingReader
+	limits     DecodeLimits
+	codings    []string
+	reader     io.Reader
+	n          int64
+	closer     io.Closer
+	err        error
+}
+
+func (d *decodedBody) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	if d.reader == nil {
+		if d.reader, d.err = newDecoder(d.compressed, d.codings); d.err != nil {
+			return 0, d.err
+		}
+	}
+	n, err := d.reader.Read(p)
+	d.n += int64(n)
+	switch {
+	case d.n > d.limits.MaxBytes:
+		err = fmt.Errorf("%w: more than %d bytes", ErrDecodedBodyTooLarge, d.limits.MaxBytes)
+	case d.n > ratioAllowance && d.n > d.compressed.n*d.limits.MaxRatio:
+		err = fmt.Errorf("%w: more than %d times the %d bytes sent", ErrDecodedBodyTooLarge, d.limits.MaxRatio, d.compressed.n)
+	case err != nil && err != io.EOF:
+		err = corruptError(err)
+	}
+	if err != nil {
+		d.err = err
+	}
+	return n, err
+}
+
+// Close closes the underlying body, draining what is left of it.
+func (d *decodedBody) Close() error {
+	if d.closer == nil {
+		return nil
+	}
+	return d.closer.Close()
+}
+
+func newDecoder(r io.Reader, codings []string) (io.Reader, error) {
+	for _, coding := range codings {
+		var err error
+		if coding == "gzip" {
+			r, err = gzip.NewReader(r)
+		} else {
+			r, err = zlib.NewReader(r)
+		}
+		if err != nil {
+			return nil, corruptError(err)
+		}
+	}
+	return r, nil
+}
+
+// corruptError wraps what the decompressors report about bad data. Other
+// errors, like the connection going away, are passed on as they are.
+func corruptError(err error) error {
+	var corrupt flate.CorruptInputError
+	if errors.As(err, &corrupt) ||
+		errors.Is(err, gzip.ErrHeader) || errors.Is(err, gzip.ErrChecksum) ||
+		errors.Is(err, zlib.ErrHeader) || errors.Is(err, zlib.ErrChecksum) || errors.Is(err, zlib.ErrDictionary) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %v", ErrCorruptEncoding, err)
+	}
+	return err
+}
@@ -0,0 +1,133 @@
+package request
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser = gzip.NewWriter(&buf)
+	if coding == "deflate" {
+		w = zlib.NewWriter(&buf)
+	}
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func encodedRequest(contentEncoding string, body []byte) string {
+	data := "POST /items HTTP/1.1\r\nHost: localhost\r\nContent-Type: application/json\r\n"
+	if contentEncoding != "" {
+		data += "Content-Encoding: " + contentEncoding + "\r\n"
+	}
+	return data + "Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + string(body)
+}
+
+func TestDecodeBody(t *testing.T) {
+	payload := []byte(`{"name":"a","count":2,"tags":["x"]}`)
+	cases := []struct {
+		name            string
+		contentEncoding string
+		body            []byte
+		err             error
+	}{
+		{"gzip", "gzip", encode(t, "gzip", payload), nil},
+		{"x-gzip", "X-Gzip", encode(t, "gzip", payload), nil},
+		{"deflate", "deflate", encode(t, "deflate", payload), nil},
+		{"stacked", "deflate, gzip", encode(t, "gzip", encode(t, "deflate", payload)), nil},
+		{"no encoding", "", payload, nil},
+		{"unsupported", "br", payload, ErrUnsupportedEncoding},
+		{"not compressed", "gzip", payload, ErrCorruptEncoding},
+		{"truncated", "deflate", encode(t, "deflate", payload)[:10], ErrCorruptEncoding},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := RequestFromReader(&chunkReader{data: encodedRequest(c.contentEncoding, c.body), numBytesPerRead: 7})
+			require.NoError(t, err)
+			err = r.DecodeBody(DecodeLimits{})
+			if c.err != nil {
+				require.ErrorIs(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			// Test: Body holds the decoded bytes, without the encoding headers
+			assert.Equal(t, payload, r.Body)
+			assert.False(t, r.Headers.Has("Content-Encoding"))
+			length, _ := r.Headers.Get("Content-Length")
+			assert.Equal(t, strconv.Itoa(len(payload)), length)
+			var item jsonItem
+			require.NoError(t, r.DecodeJSON(&item))
+			assert.Equal(t, "a", item.Name)
+		})
+	}
+}
+
+func TestDecodeBodyLimits(t *testing.T) {
+	bomb := encode(t, "gzip", make([]byte, 1<<20))
+	random := []byte(strings.Repeat("0123456789abcdef", 1<<12))
+	cases := []struct {
+		name   string
+		body   []byte
+		limits DecodeLimits
+		err    error
+	}{
+		{"ratio", bomb, DecodeLimits{}, ErrDecodedBodyTooLarge},
+		{"ratio raised", bomb, DecodeLimits{MaxRatio: 1 << 20}, nil},
+		{"size", encode(t, "gzip", random), DecodeLimits{MaxBytes: 1000, MaxRatio: 1 << 20}, ErrDecodedBodyTooLarge},
+		{"under allowance", encode(t, "gzip", make([]byte, 1000)), DecodeLimits{MaxRatio: 2}, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Test: Decompression stops once a limit is passed
+			r, err := RequestFromReader(strings.NewReader(encodedRequest("gzip", c.body)))
+			require.NoError(t, err)
+			err = r.DecodeBody(c.limits)
+			if c.err != nil {
+				require.ErrorIs(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestDecodeStreamingBody(t *testing.T) {
+	payload := []byte("hello world!\n")
+	data := encodedRequest("gzip", encode(t, "gzip", payload)) +
+		encodedRequest("gzip", encode(t, "gzip", make([]byte, 1<<20))) +
+		"GET /last HTTP/1.1\r\nHost: localhost\r\n\r\n"
+	reader := NewReader(&chunkReader{data: data, numBytesPerRead: 3})
+
+	// Test: Streaming body is decoded as it is read
+	r, err := reader.ReadStreamingRequest()
+	require.NoError(t, err)
+	require.NoError(t, r.DecodeBody(DecodeLimits{}))
+	assert.False(t, r.Headers.Has("Content-Length"))
+	body, err := io.ReadAll(r.BodyReader)
+	require.NoError(t, err)
+	assert.Equal(t, payload, body)
+	require.NoError(t, r.BodyReader.Close())
+
+	// Test: Limits surface from the read, and closing still skips the body
+	r, err = reader.ReadStreamingRequest()
+	require.NoError(t, err)
+	require.NoError(t, r.DecodeBody(DecodeLimits{}))
+	_, err = io.ReadAll(r.BodyReader)
+	require.ErrorIs(t, err, ErrDecodedBodyTooLarge)
+	require.NoError(t, r.BodyReader.Close())
+
+	r, err = reader.ReadStreamingRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/last", r.RequestLine.RequestTarget)
+}
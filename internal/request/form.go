@@ -0,0 +1,279 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"os"
+	"slices"
+
+	"MyOwnHTTP/internal/headers"
+)
+
+// Errors from parsing a form body. ErrFormTooLarge is for forms that go
+// over one of the FormLimits and is worth a 413, the others a 400 or 415.
+var (
+	ErrNotForm       = errors.New("request body is not a form")
+	ErrMalformedForm = errors.New("malformed form body")
+	ErrFormTooLarge  = errors.New("form too large")
+)
+
+// FormLimits bounds how much of a form body is accepted. Zero fields use
+// the defaults below.
+type FormLimits struct {
+	// MaxMemory is how many bytes of values and uploaded files are kept
+	// in memory. Files past it are written to temporary files instead,
+	// while values past it fail the form.
+	MaxMemory int64
+	// MaxParts caps the number of multipart parts, files and values
+	// together.
+	MaxParts int
+	// MaxPartBytes caps the size of a single part, and of a whole
+	// url-encoded body.
+	MaxPartBytes int64
+	// TempDir is where files past MaxMemory go, os.TempDir() if empty.
+	TempDir string
+}
+
+const (
+	defaultFormMaxMemory    = 10 << 20
+	defaultFormMaxParts     = 1000
+	defaultFormMaxPartBytes = 32 << 20
+)
+
+// MultipartForm is a parsed multipart/form-data body.
+type MultipartForm struct {
+	Value Values
+	File  map[string][]*FileHeader
+}
+
+// FileHeader describes an uploaded file. Its contents are either held in
+// memory or in a temporary file, which Open hides.
+type FileHeader struct {
+	// Filename is the name the client gave, without any directories.
+	Filename    string
+	ContentType string
+	Header      *headers.Headers
+	Size        int64
+	content     []byte
+	tmpFile     string
+}
+
+// Open returns the contents of the file.
+func (f *FileHeader) Open() (io.ReadCloser, error) {
+	if f.tmpFile != "" {
+		return os.Open(f.tmpFile)
+	}
+	return io.NopCloser(bytes.NewReader(f.content)), nil
+}
+
+// RemoveAll deletes the temporary files behind the form's uploads.
+func (f *MultipartForm) RemoveAll() error {
+	var errs []error
+	for _, files := range f.File {
+		for _, file := range files {
+			if file.tmpFile == "" {
+				continue
+			}
+			if err := os.Remove(file.tmpFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ParseForm is ParseFormWithLimits with the default limits.
+func (r *Request) ParseForm() error {
+	return r.ParseFormWithLimits(FormLimits{})
+}
+
+// ParseFormWithLimits reads an application/x-www-form-urlencoded or
+// multipart/form-data body into r.Form, and for multipart bodies also
+// r.MultipartForm. The query string is left to r.Query. The body can
+// only be read once, so later calls return the first call's result.
+func (r *Request) ParseFormWithLimits(limits FormLimits) error {
+	if r.formParsed {
+		return r.formErr
+	}
+	r.formParsed = true
+	limits = limits.withDefaults()
+
+	contentType, _ := r.Headers.Get("Content-Type")
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	switch {
+	case err != nil:
+		r.formErr = fmt.Errorf("%w: Content-Type %q", ErrNotForm, contentType)
+	case mediaType == "application/x-www-form-urlencoded":
+		r.formErr = r.parseURLEncodedForm(limits)
+	case mediaType == "multipart/form-data" && params["boundary"] != "":
+		r.formErr = r.parseMultipartForm(params["boundary"], limits)
+	case mediaType == "multipart/form-data":
+		r.formErr = fmt.Errorf("%w: multipart body without a boundary", ErrMalformedForm)
+	default:
+		r.formErr = fmt.Errorf("%w: Content-Type %q", ErrNotForm, mediaType)
+	}
+	if r.Form == nil {
+		r.Form = Values{}
+	}
+	return r.formErr
+}
+
+// FormValue returns the first value of a form field, parsing the form
+// with the default limits if that hasn't happened yet. Parse errors are
+// ignored; call ParseForm to see them.
+func (r *Request) FormValue(key string) string {
+	r.ParseForm()
+	return r.Form.Get(key)
+}
+
+// FormFile returns the first file uploaded under key.
+func (r *Request) FormFile(key string) (*FileHeader, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	if r.MultipartForm == nil || len(r.MultipartForm.File[key]) == 0 {
+		return nil, fmt.Errorf("no file uploaded as %q", key)
+	}
+	return r.MultipartForm.File[key][0], nil
+}
+
+// RemoveFormFiles deletes any temporary files ParseForm made. The server
+// calls it once the handler is done.
+func (r *Request) RemoveFormFiles() error {
+	if r.MultipartForm == nil {
+		return nil
+	}
+	return r.MultipartForm.RemoveAll()
+}
+
+func (l FormLimits) withDefaults() FormLimits {
+	if l.MaxMemory <= 0 {
+		l.MaxMemory = defaultFormMaxMemory
+	}
+	if l.MaxParts <= 0 {
+		l.MaxParts = defaultFormMaxParts
+	}
+	if l.MaxPartBytes <= 0 {
+		l.MaxPartBytes = defaultFormMaxPartBytes
+	}
+	return l
+}
+
+// body reads the request body whether it was buffered or is streaming.
+func (r *Request) body() io.Reader {
+	if r.BodyReader != nil {
+		return r.BodyReader
+	}
+	return bytes.NewReader(r.Body)
+}
+
+func (r *Request) parseURLEncodedForm(limits FormLimits) error {
+	data, err := io.ReadAll(io.LimitReader(r.body(), limits.MaxPartBytes+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > limits.MaxPartBytes {
+		return fmt.Errorf("%w: body over %d bytes", ErrFormTooLarge, limits.MaxPartBytes)
+	}
+	r.Form, err = ParseQuery(string(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformedForm, err)
+	}
+	return nil
+}
+
+func (r *Request) parseMultipartForm(boundary string, limits FormLimits) error {
+	form := &MultipartForm{Value: Values{}, File: map[string][]*FileHeader{}}
+	r.MultipartForm = form
+	r.Form = form.Value
+	mr := multipart.NewReader(r.body(), boundary)
+	memory := limits.MaxMemory
+	for parts := 0; ; parts++ {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrMalformedForm, err)
+		}
+		if parts == limits.MaxParts {
+			return fmt.Errorf("%w: more than %d parts", ErrFormTooLarge, limits.MaxParts)
+		}
+		name := part.FormName()
+		if name == "" {
+			// not a form field, as in a part without Content-Disposition
+			continue
+		}
+		data := io.LimitReader(part, limits.MaxPartBytes+1)
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(data, memory+1))
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrMalformedForm, err)
+			}
+			if int64(len(value)) > limits.MaxPartBytes {
+				return fmt.Errorf("%w: field %q over %d bytes", ErrFormTooLarge, name, limits.MaxPartBytes)
+			}
+			// values have nowhere to spill to, so they share the memory
+			// budget with files and end the form once it's used up
+			if int64(len(value)) > memory {
+				return fmt.Errorf("%w: values over %d bytes in total", ErrFormTooLarge, limits.MaxMemory)
+			}
+			memory -= int64(len(value))
+			form.Value[name] = append(form.Value[name], string(value))
+			continue
+		}
+
+		file := &FileHeader{
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Header:      headers.NewHeaders(),
+		}
+		for _, key := range slices.Sorted(maps.Keys(part.Header)) {
+			for _, value := range part.Header[key] {
+				file.Header.Add(key, value)
+			}
+		}
+		// append before storing so RemoveAll finds a half-written file
+		form.File[name] = append(form.File[name], file)
+		if err := file.store(data, &memory, limits.TempDir); err != nil {
+			return err
+		}
+		if file.Size > limits.MaxPartBytes {
+			return fmt.Errorf("%w: file %q over %d bytes", ErrFormTooLarge, file.Filename, limits.MaxPartBytes)
+		}
+	}
+}
+
+// store keeps the file in memory while it fits in what is left of the
+// memory budget, and moves it to a temporary file once it doesn't.
+func (f *FileHeader) store(data io.Reader, memory *int64, tempDir string) error {
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, data, *memory+1)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("%w: %v", ErrMalformedForm, err)
+	}
+	if n <= *memory {
+		*memory -= n
+		f.content = buf.Bytes()
+		f.Size = n
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(tempDir, "upload-*")
+	if err != nil {
+		return err
+	}
+	defer tmp.Close()
+	f.tmpFile = tmp.Name()
+	f.Size, err = io.Copy(tmp, io.MultiReader(&buf, data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformedForm, err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package request
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func formRequest(t *testing.T, contentType, body string) *Request {
+	t.Helper()
+	data := "POST /upload HTTP/1.1\r\nHost: localhost\r\n" +
+		"Content-Type: " + contentType + "\r\n" +
+		"Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+	r, err := RequestFromReader(&chunkReader{data: data, numBytesPerRead: 7})
+	require.NoError(t, err)
+	return r
+}
+
+// multipartBody joins parts, each given as its headers and content, with
+// the "xyz" boundary.
+func multipartBody(parts ...[2]string) string {
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteString("--xyz\r\n" + part[0] + "\r\n\r\n" + part[1] + "\r\n")
+	}
+	b.WriteString("--xyz--\r\n")
+	return b.String()
+}
+
+func readFile(t *testing.T, file *FileHeader) string {
+	t.Helper()
+	f, err := file.Open()
+	require.NoError(t, err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestURLEncodedForm(t *testing.T) {
+	// Test: Fields with repeated keys and escapes
+	r := formRequest(t, "application/x-www-form-urlencoded", "name=caf%C3%A9+au+lait&tag=a&tag=b")
+	require.NoError(t, r.ParseForm())
+	assert.Equal(t, "café au lait", r.FormValue("name"))
+	assert.Equal(t, []string{"a", "b"}, r.Form["tag"])
+	assert.Nil(t, r.MultipartForm)
+
+	// Test: Query parameters stay out of the form
+	data := "POST /upload?from=query HTTP/1.1\r\nHost: localhost\r\nContent-Type: application/x-www-form-urlencoded\r\nContent-Length: 7\r\n\r\nfrom=me"
+	r, err := RequestFromReader(&chunkReader{data: data, numBytesPerRead: 3})
+	require.NoError(t, err)
+	assert.Equal(t, "me", r.FormValue("from"))
+	assert.Equal(t, "query", r.Query().Get("from"))
+
+	// Test: Bad escape
+	r = formRequest(t, "application/x-www-form-urlencoded", "a=%zz")
+	assert.ErrorIs(t, r.ParseForm(), ErrMalformedForm)
+
+	// Test: Body over the limit
+	r = formRequest(t, "application/x-www-form-urlencoded", "a="+strings.Repeat("b", 20))
+	assert.ErrorIs(t, r.ParseFormWithLimits(FormLimits{MaxPartBytes: 10}), ErrFormTooLarge)
+
+	// Test: Not a form
+	r = formRequest(t, "application/json", "{}")
+	assert.ErrorIs(t, r.ParseForm(), ErrNotForm)
+	assert.Equal(t, "", r.FormValue("a"))
+
+	// Test: Streamed bodies can be parsed too
+	data = "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Type: application/x-www-form-urlencoded\r\nTransfer-Encoding: chunked\r\n\r\n3\r\na=1\r\n4\r\n&b=2\r\n0\r\n\r\n"
+	r, err = NewReader(&chunkReader{data: data, numBytesPerRead: 3}).ReadStreamingRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "1", r.FormValue("a"))
+	assert.Equal(t, "2", r.FormValue("b"))
+}
+
+func TestMultipartForm(t *testing.T) {
+	body := multipartBody(
+		[2]string{`Content-Disposition: form-data; name="title"`, "Holiday"},
+		[2]string{`Content-Disposition: form-data; name="tag"`, "sea"},
+		[2]string{`Content-Disposition: form-data; name="tag"`, "sun"},
+		[2]string{"Content-Disposition: form-data; name=\"photo\"; filename=\"../../beach.jpg\"\r\nContent-Type: image/jpeg", "small"},
+		[2]string{"Content-Disposition: form-data; name=\"photo\"; filename=\"big.raw\"", strings.Repeat("x", 100)},
+	)
+	r := formRequest(t, `multipart/form-data; boundary="xyz"`, body)
+	tmpDir := t.TempDir()
+	require.NoError(t, r.ParseFormWithLimits(FormLimits{MaxMemory: 50, TempDir: tmpDir}))
+
+	// Test: Values
+	assert.Equal(t, "Holiday", r.FormValue("title"))
+	assert.Equal(t, []string{"sea", "sun"}, r.Form["tag"])
+	assert.Equal(t, r.Form, r.MultipartForm.Value)
+
+	// Test: Small file stays in memory, without its directories
+	file, err := r.FormFile("photo")
+	require.NoError(t, err)
+	assert.Equal(t, "beach.jpg", file.Filename)
+	assert.Equal(t, "image/jpeg", file.ContentType)
+	assert.Equal(t, int64(5), file.Size)
+	assert.Equal(t, "small", readFile(t, file))
+	value, err := file.Header.Get("Content-Disposition")
+	require.NoError(t, err)
+	assert.Contains(t, value, "beach.jpg")
+
+	// Test: File past the memory threshold spills to disk
+	big := r.MultipartForm.File["photo"][1]
+	assert.Equal(t, "big.raw", big.Filename)
+	assert.Equal(t, int64(100), big.Size)
+	assert.Equal(t, strings.Repeat("x", 100), readFile(t, big))
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// Test: RemoveFormFiles cleans up the temporary files
+	require.NoError(t, r.RemoveFormFiles())
+	entries, err = os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// Test: Missing file
+	_, err = r.FormFile("nope")
+	assert.Error(t, err)
+}
+
+func TestMultipartFormErrors(t *testing.T) {
+	field := [2]string{`Content-Disposition: form-data; name="a"`, "12345"}
+	file := [2]string{`Content-Disposition: form-data; name="f"; filename="f.txt"`, strings.Repeat("y", 30)}
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		limits      FormLimits
+		err         error
+	}{
+		{"too many parts", "multipart/form-data; boundary=xyz", multipartBody(field, field, field), FormLimits{MaxParts: 2}, ErrFormTooLarge},
+		{"field too large", "multipart/form-data; boundary=xyz", multipartBody(field), FormLimits{MaxPartBytes: 4}, ErrFormTooLarge},
+		{"values over memory", "multipart/form-data; boundary=xyz", multipartBody(field, field, field), FormLimits{MaxMemory: 12}, ErrFormTooLarge},
+		{"file too large in memory", "multipart/form-data; boundary=xyz", multipartBody(file), FormLimits{MaxPartBytes: 20}, ErrFormTooLarge},
+		{"file too large on disk", "multipart/form-data; boundary=xyz", multipartBody(file), FormLimits{MaxPartBytes: 20, MaxMemory: 10}, ErrFormTooLarge},
+		{"no boundary", "multipart/form-data", multipartBody(field), FormLimits{}, ErrMalformedForm},
+		{"wrong boundary", "multipart/form-data; boundary=abc", multipartBody(field), FormLimits{}, ErrMalformedForm},
+		{"truncated", "multipart/form-data; boundary=xyz", "--xyz\r\nContent-Disposition: form-data; name=\"a\"\r\n\r\nabc", FormLimits{}, ErrMalformedForm},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.limits.TempDir = t.TempDir()
+			r := formRequest(t, c.contentType, c.body)
+			err := r.ParseFormWithLimits(c.limits)
+			assert.ErrorIs(t, err, c.err)
+			// Test: The error sticks on later calls
+			assert.Equal(t, err, r.ParseForm())
+			require.NoError(t, r.RemoveFormFiles())
+			entries, err := os.ReadDir(c.limits.TempDir)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
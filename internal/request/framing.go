@@ -0,0 +1,96 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Errors for requests whose body framing can't be trusted. Front ends
+// and back ends that disagree on where such a body ends are how requests
+// get smuggled, so these are rejected rather than guessed at.
+var (
+	ErrAmbiguousFraming = errors.New("ambiguous message framing")
+)
+
+// noContentLength marks a request without a Content-Length header.
+const noContentLength = -1
+
+// checkFraming works out how the body is delimited once the headers are
+// in, following RFC 9112 section 6.3.
+func (r *Request) checkFraming() error {
+	r.contentLength = noContentLength
+	_, hasTE := r.transferCodings()
+	lengths := r.Headers.Values("Content-Length")
+	if hasTE && len(lengths) > 0 {
+		return fmt.Errorf("%w: both Transfer-Encoding and Content-Length", ErrAmbiguousFraming)
+	}
+	if hasTE {
+		return r.checkTransferCodings()
+	}
+	if len(lengths) > 0 {
+		length, err := parseContentLength(lengths)
+		if err != nil {
+			return err
+		}
+		r.contentLength = length
+	}
+	return nil
+}
+
+func (r *Request) transferCodings() ([]string, bool) {
+	values := r.Headers.Values("Transfer-Encoding")
+	if len(values) == 0 {
+		return nil, false
+	}
+	var codings []string
+	for _, value := range values {
+		for coding := range strings.SplitSeq(value, ",") {
+			if coding = strings.TrimSpace(coding); coding != "" {
+				codings = append(codings, strings.ToLower(coding))
+			}
+		}
+	}
+	return codings, true
+}
+
+// checkTransferCodings needs chunked as the last coding, applied once.
+// Anything else would leave the body running until the connection
+// closes, which a request can't do. Codings applied before chunked are
+// left on the body for the handler to undo.
+func (r *Request) checkTransferCodings() error {
+	codings, _ := r.transferCodings()
+	if len(codings) == 0 || codings[len(codings)-1] != "chunked" {
+		return fmt.Errorf("%w: Transfer-Encoding must end with chunked: %v", ErrAmbiguousFraming, codings)
+	}
+	if slices.Index(codings, "chunked") != len(codings)-1 {
+		return fmt.Errorf("%w: chunked applied more than once: %v", ErrAmbiguousFraming, codings)
+	}
+	r.chunked = true
+	return nil
+}
+
+// parseContentLength accepts repeated Content-Length values, either as
+// separate fields or a list, as long as they all agree.
+func parseContentLength(values []string) (int, error) {
+	length := noContentLength
+	for _, value := range values {
+		for part := range strings.SplitSeq(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" || strings.TrimLeft(part, "0123456789") != "" {
+				return 0, fmt.Errorf("%w: invalid content length: %s", ErrBodyLengthMismatch, value)
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("%w: invalid content length: %s", ErrBodyLengthMismatch, value)
+			}
+			if length != noContentLength && n != length {
+				return 0, fmt.Errorf("%w: conflicting content lengths: %v", ErrAmbiguousFraming, values)
+			}
+			length = n
+		}
+	}
+	return length, nil
+}
@@ -0,0 +1,46 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidHost is for HTTP/1.1 requests without exactly one valid Host
+// header, which RFC 9112 requires.
+var ErrInvalidHost = errors.New("missing or invalid Host header")
+
+// checkHost settles which host the request is for. An absolute-form or
+// authority-form target names it and takes precedence over the Host
+// header, which still has to be there.
+func (r *Request) checkHost() error {
+	values := r.Headers.Values("Host")
+	switch {
+	case len(values) == 0:
+		return fmt.Errorf("%w: no Host header", ErrInvalidHost)
+	case len(values) > 1:
+		return fmt.Errorf("%w: %d Host headers", ErrInvalidHost, len(values))
+	case !isValidHost(values[0]):
+		return fmt.Errorf("%w: %q", ErrInvalidHost, values[0])
+	}
+	r.Host = values[0]
+	if r.URL.Host != "" {
+		r.Host = r.URL.Host
+	}
+	return nil
+}
+
+// isValidHost checks for a uri-host with an optional port. Userinfo is
+// not allowed, and an empty host is, for targets without an authority.
+func isValidHost(host string) bool {
+	for i := 0; i < len(host); i++ {
+		c := host[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("-._~!$&'()*+,;=%:[]", c) != -1:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// Errors from DecodeJSON. ErrMalformedJSON covers anything wrong with
+// the document itself, including fields a strict decode doesn't know.
+var (
+	ErrNotJSON       = errors.New("request body is not JSON")
+	ErrMalformedJSON = errors.New("malformed JSON body")
+	ErrJSONTooLarge  = errors.New("JSON body too large")
+)
+
+// JSONOptions controls DecodeJSONWithOptions.
+type JSONOptions struct {
+	// Strict rejects object fields that v has nowhere to put.
+	Strict bool
+	// MaxBytes caps the size of the body. Zero uses defaultJSONMaxBytes.
+	MaxBytes int64
+}
+
+const defaultJSONMaxBytes = 1 << 20
+
+// DecodeJSON decodes the body into v, ignoring unknown fields.
+func (r *Request) DecodeJSON(v any) error {
+	return r.DecodeJSONWithOptions(v, JSONOptions{})
+}
+
+// DecodeJSONStrict decodes the body into v, rejecting unknown fields and
+// bodies over maxBytes.
+func (r *Request) DecodeJSONStrict(v any, maxBytes int64) error {
+	return r.DecodeJSONWithOptions(v, JSONOptions{Strict: true, MaxBytes: maxBytes})
+}
+
+// DecodeJSONWithOptions decodes a body holding exactly one JSON value
+// into v. A Content-Type other than application/json or a +json type is
+// refused; a missing one is let through.
+func (r *Request) DecodeJSONWithOptions(v any, options JSONOptions) error {
+	if contentType, err := r.Headers.Get("Content-Type"); err == nil {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+			return fmt.Errorf("%w: Content-Type %q", ErrNotJSON, contentType)
+		}
+	}
+	maxBytes := options.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultJSONMaxBytes
+	}
+
+	body := &countingReader{r: io.LimitReader(r.body(), maxBytes+1)}
+	decoder := json.NewDecoder(body)
+	if options.Strict {
+		decoder.DisallowUnknownFields()
+	}
+	err := decoder.Decode(v)
+	if err == nil {
+		// the body has to end after the one value
+		if _, tokenErr := decoder.Token(); tokenErr != io.EOF {
+			err = errors.New("unexpected data after the JSON value")
+		}
+	}
+	if body.n > maxBytes {
+		return fmt.Errorf("%w: more than %d bytes", ErrJSONTooLarge, maxBytes)
+	}
+	if errors.Is(err, ErrDecodedBodyTooLarge) || errors.Is(err, ErrCorruptEncoding) {
+		// the body failed to decompress, see DecodeBody
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrMalformedJSON, describeJSONError(err))
+	}
+	return nil
+}
+
+// describeJSONError rewords the decoder's errors for a client, who knows
+// the document but not our Go types.
+func describeJSONError(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return "empty body"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "body ends in the middle of a value"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("%v at byte %d", syntaxErr, syntaxErr.Offset)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return fmt.Sprintf("field %q can't be a JSON %s", typeErr.Field, typeErr.Value)
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("expected a different type than a JSON %s", typeErr.Value)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return "unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field ")
+	}
+	return err.Error()
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
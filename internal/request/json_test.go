@@ -0,0 +1,84 @@
+package request
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type jsonItem struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Tags  []string `json:"tags"`
+}
+
+func jsonRequest(t *testing.T, contentType, body string) *Request {
+	t.Helper()
+	data := "POST /items HTTP/1.1\r\nHost: localhost\r\n"
+	if contentType != "" {
+		data += "Content-Type: " + contentType + "\r\n"
+	}
+	data += "Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+	r, err := RequestFromReader(&chunkReader{data: data, numBytesPerRead: 5})
+	require.NoError(t, err)
+	return r
+}
+
+func TestDecodeJSON(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		options     JSONOptions
+		err         error
+		detail      string
+	}{
+		{"valid", "application/json", `{"name":"a","count":2,"tags":["x"]}`, JSONOptions{}, nil, ""},
+		{"charset param", "application/json; charset=utf-8", `{"name":"a"}`, JSONOptions{}, nil, ""},
+		{"json suffix", "application/merge-patch+json", `{"name":"a"}`, JSONOptions{}, nil, ""},
+		{"no content type", "", `{"name":"a"}`, JSONOptions{}, nil, ""},
+		{"trailing whitespace", "application/json", "{\"name\":\"a\"}\n\n", JSONOptions{}, nil, ""},
+		{"unknown field allowed", "application/json", `{"name":"a","extra":1}`, JSONOptions{}, nil, ""},
+		{"unknown field strict", "application/json", `{"name":"a","extra":1}`, JSONOptions{Strict: true}, ErrMalformedJSON, `unknown field "extra"`},
+		{"wrong content type", "text/plain", `{"name":"a"}`, JSONOptions{}, ErrNotJSON, ""},
+		{"too large", "application/json", `{"name":"` + strings.Repeat("a", 100) + `"}`, JSONOptions{MaxBytes: 50}, ErrJSONTooLarge, ""},
+		{"empty", "application/json", "", JSONOptions{}, ErrMalformedJSON, "empty body"},
+		{"truncated", "application/json", `{"name":`, JSONOptions{}, ErrMalformedJSON, "middle of a value"},
+		{"syntax error", "application/json", `{"name" "a"}`, JSONOptions{}, ErrMalformedJSON, "at byte 9"},
+		{"wrong type", "application/json", `{"count":"two"}`, JSONOptions{}, ErrMalformedJSON, `field "count" can't be a JSON string`},
+		{"two values", "application/json", `{"name":"a"}{"name":"b"}`, JSONOptions{}, ErrMalformedJSON, "after the JSON value"},
+		{"trailing garbage", "application/json", `{"name":"a"} x`, JSONOptions{}, ErrMalformedJSON, "after the JSON value"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := jsonRequest(t, c.contentType, c.body)
+			var item jsonItem
+			err := r.DecodeJSONWithOptions(&item, c.options)
+			if c.err != nil {
+				assert.ErrorIs(t, err, c.err)
+				assert.Contains(t, err.Error(), c.detail)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "a", item.Name)
+		})
+	}
+
+	// Test: Shorthands
+	var item jsonItem
+	r := jsonRequest(t, "application/json", `{"name":"a","count":2,"tags":["x","y"],"extra":true}`)
+	require.NoError(t, r.DecodeJSON(&item))
+	assert.Equal(t, jsonItem{Name: "a", Count: 2, Tags: []string{"x", "y"}}, item)
+	r = jsonRequest(t, "application/json", `{"name":"a","extra":true}`)
+	assert.ErrorIs(t, r.DecodeJSONStrict(&item, 0), ErrMalformedJSON)
+
+	// Test: Streamed body
+	data := "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Type: application/json\r\nTransfer-Encoding: chunked\r\n\r\n6\r\n{\"name\r\n6\r\n\":\"b\"}\r\n0\r\n\r\n"
+	r, err := NewReader(&chunkReader{data: data, numBytesPerRead: 3}).ReadStreamingRequest()
+	require.NoError(t, err)
+	require.NoError(t, r.DecodeJSONStrict(&item, 100))
+	assert.Equal(t, "b", item.Name)
+}
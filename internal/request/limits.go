@@ -0,0 +1,73 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrRequestLineTooLong = errors.New("request line too long")
+	ErrHeadersTooLarge    = errors.New("request headers too large")
+	ErrBodyTooLarge       = errors.New("request body too large")
+)
+
+// Limits bounds how much of a request is accepted. A zero field means no
+// limit.
+type Limits struct {
+	MaxRequestLineBytes int
+	// MaxHeaderBytes applies to the header section and the trailers
+	// together.
+	MaxHeaderBytes int
+	MaxHeaderCount int
+	MaxBodyBytes   int64
+}
+
+// maxChunkSizeLineBytes keeps a chunk-size line without CRLF from growing
+// the buffer forever. Extensions are the only thing that can make it long.
+const maxChunkSizeLineBytes = 4096
+
+// lineLength is the length of the line at the start of data, or of what
+// has arrived of it so far.
+func lineLength(data []byte) int {
+	idx := bytes.Index(data, []byte(crlf))
+	if idx == -1 {
+		return len(data)
+	}
+	return idx
+}
+
+func (r *Request) checkRequestLine(data []byte) error {
+	maxBytes := r.limits.MaxRequestLineBytes
+	if maxBytes > 0 && lineLength(data) > maxBytes {
+		return fmt.Errorf("%w: more than %d bytes", ErrRequestLineTooLong, maxBytes)
+	}
+	return nil
+}
+
+func (r *Request) checkHeaderLine(data []byte) error {
+	maxBytes := r.limits.MaxHeaderBytes
+	if maxBytes > 0 && r.headerBytes+lineLength(data) > maxBytes {
+		return fmt.Errorf("%w: more than %d bytes", ErrHeadersTooLarge, maxBytes)
+	}
+	return nil
+}
+
+// countHeaderLine records a parsed header or trailer line of n bytes.
+func (r *Request) countHeaderLine(n int) error {
+	r.headerBytes += n
+	r.headerCount++
+	maxCount := r.limits.MaxHeaderCount
+	if maxCount > 0 && r.headerCount > maxCount {
+		return fmt.Errorf("%w: more than %d fields", ErrHeadersTooLarge, maxCount)
+	}
+	return nil
+}
+
+func (r *Request) checkBodySize(size int64) error {
+	maxBytes := r.limits.MaxBodyBytes
+	if maxBytes > 0 && size > maxBytes {
+		return fmt.Errorf("%w: more than %d bytes", ErrBodyTooLarge, maxBytes)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package request
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Values maps query or form keys to their values, in the order they
+// were sent. A key can appear more than once.
+type Values map[string][]string
+
+// Get returns the first value for key, or "" if there is none.
+func (v Values) Get(key string) string {
+	if values := v[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Has reports whether key was sent at all, even without a value.
+func (v Values) Has(key string) bool {
+	_, ok := v[key]
+	return ok
+}
+
+// ParseQuery decodes an application/x-www-form-urlencoded string such as
+// a query. Pairs that fail to decode are skipped and the first such
+// error is returned along with everything that did decode.
+func ParseQuery(query string) (Values, error) {
+	values := Values{}
+	var firstErr error
+	for pair := range strings.SplitSeq(query, "&") {
+		if pair == "" {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		key, err := unescape(rawKey, true)
+		if err == nil {
+			var value string
+			if value, err = unescape(rawValue, true); err == nil {
+				values[key] = append(values[key], value)
+				continue
+			}
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%q: %w", pair, err)
+		}
+	}
+	return values, firstErr
+}
@@ -2,10 +2,10 @@ package request
 
 import (
 	"bytes"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
-	"strconv"
 	"strings"
 	"unicode"
 
@@ -14,9 +14,41 @@ import (
 
 type Request struct {
 	RequestLine RequestLine
-	Headers     headers.Headers
+	Headers     *headers.Headers
 	Body        []byte
-	state       requestState
+	Trailers    *headers.Headers
+	// BodyReader is set instead of Body for requests read with
+	// ReadStreamingRequest.
+	BodyReader io.ReadCloser
+	// Form holds the fields of a url-encoded or multipart body once
+	// ParseForm has run, and MultipartForm the uploaded files as well.
+	Form          Values
+	MultipartForm *MultipartForm
+	// Params holds the path parameters a router matched for this request.
+	Params map[string]string
+	// URL is the parsed request target.
+	URL *URL
+	// Host is the host the request is for: the authority of an
+	// absolute-form target, otherwise the Host header.
+	Host string
+	// TLS describes the connection the request came in on, or is nil for
+	// plaintext connections.
+	TLS   *tls.ConnectionState
+	state requestState
+	// bodyRead counts body bytes parsed so far; Body may have been
+	// handed off to a streaming reader in the meantime
+	bodyRead int
+	// contentLength and chunked say how the body is framed, as worked out
+	// by checkFraming once the headers are in
+	contentLength int
+	chunked       bool
+	// chunkRemaining is how much of the current chunk is still to be read
+	chunkRemaining int
+	limits         Limits
+	headerBytes    int
+	formParsed     bool
+	formErr        error
+	headerCount    int
 }
 
 type RequestLine struct {
@@ -32,6 +64,10 @@ const (
 	requestStateInitialized requestState = iota
 	requestStateParsingHeaders
 	requestStateParsingBody
+	requestStateParsingChunkSize
+	requestStateParsingChunkData
+	requestStateParsingChunkDataEnd
+	requestStateParsingTrailers
 	requestStateDone
 )
 
@@ -40,53 +76,151 @@ const (
 	bufferSize = 8
 )
 
+// Errors for requests that can't be parsed. Every parse error wraps one
+// of these (or one of the limit errors) so callers can pick a response.
+var (
+	ErrMalformedRequestLine = errors.New("malformed request line")
+	ErrUnsupportedVersion   = errors.New("unsupported HTTP version")
+	ErrMalformedHeader      = errors.New("malformed header")
+	ErrBodyLengthMismatch   = errors.New("body does not match its framing")
+	ErrIncompleteRequest    = errors.New("incomplete request")
+)
+
+// Reader reads successive requests off a single connection. Bytes read
+// past the end of one request are kept for the next call, so pipelined
+// requests are not lost.
+type Reader struct {
+	Limits      Limits
+	src         io.Reader
+	buf         []byte
+	readToIndex int
+}
+
+func NewReader(src io.Reader) *Reader {
+	return &Reader{
+		src: src,
+		buf: make([]byte, bufferSize, bufferSize),
+	}
+}
+
 func RequestFromReader(reader io.Reader) (*Request, error) {
-	buf := make([]byte, bufferSize, bufferSize)
-	readToIndex := 0
-	req := &Request{
-		state:   requestStateInitialized,
-		Headers: headers.NewHeaders(),
+	return NewReader(reader).ReadRequest()
+}
+
+func (r *Reader) ReadRequest() (*Request, error) {
+	req, err := r.ReadHeaders()
+	if err != nil {
+		return nil, err
 	}
-	for req.state != requestStateDone {
-		if readToIndex >= len(buf) {
-			newBuf := make([]byte, len(buf)*2)
-			copy(newBuf, buf)
-			buf = newBuf
-		}
+	if err := r.ReadBody(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
 
-		numBytesRead, err := reader.Read(buf[readToIndex:])
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				if req.state != requestStateDone {
-					return nil, fmt.Errorf("incomplete request, in state: %d, read n bytes on EOF: %d", req.state, numBytesRead)
-				}
-				req.state = requestStateDone
-				break
-			}
+// ReadHeaders reads a request up to the end of its headers. The body is
+// then read with ReadBody or StreamBody, which lets callers treat the
+// two phases differently, for instance with separate timeouts.
+func (r *Reader) ReadHeaders() (*Request, error) {
+	req := newRequest(r.Limits)
+	for req.state == requestStateInitialized || req.state == requestStateParsingHeaders {
+		if err := r.advance(req); err != nil {
 			return nil, err
 		}
-		readToIndex += numBytesRead
+	}
+	return req, nil
+}
 
-		numBytesParsed, err := req.parse(buf[:readToIndex])
-		if err != nil {
-			return nil, err
+// ReadBody reads the rest of a request returned by ReadHeaders into its
+// Body.
+func (r *Reader) ReadBody(req *Request) error {
+	for req.state != requestStateDone {
+		if err := r.advance(req); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		copy(buf, buf[numBytesParsed:])
-		readToIndex -= numBytesParsed
+// Buffered is the number of bytes read from the source that have not
+// been parsed yet, i.e. the start of the next pipelined request.
+func (r *Reader) Buffered() int {
+	return r.readToIndex
+}
+
+func newRequest(limits Limits) *Request {
+	return &Request{
+		limits:   limits,
+		state:    requestStateInitialized,
+		Headers:  headers.NewHeaders(),
+		Trailers: headers.NewHeaders(),
 	}
-	return req, nil
+}
+
+// advance parses whatever is buffered and, when that is not enough to
+// make progress, reads more from the source.
+func (r *Reader) advance(req *Request) error {
+	// leftover bytes from the previous request may already hold this
+	// one, so parse before reading
+	numBytesParsed, err := req.parse(r.buf[:r.readToIndex])
+	if err != nil {
+		return err
+	}
+	copy(r.buf, r.buf[numBytesParsed:r.readToIndex])
+	r.readToIndex -= numBytesParsed
+	if numBytesParsed > 0 || req.state == requestStateDone {
+		return nil
+	}
+
+	if r.readToIndex >= len(r.buf) {
+		r.grow(len(r.buf) * 2)
+	}
+
+	numBytesRead, err := r.src.Read(r.buf[r.readToIndex:])
+	r.readToIndex += numBytesRead
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			return err
+		}
+		if numBytesRead > 0 {
+			return nil
+		}
+		if req.state == requestStateInitialized && r.readToIndex == 0 {
+			// peer closed the connection between requests
+			return io.EOF
+		}
+		if req.state >= requestStateParsingBody {
+			return fmt.Errorf("%w: connection closed in state %d", ErrBodyLengthMismatch, req.state)
+		}
+		return fmt.Errorf("%w: connection closed in state %d", ErrIncompleteRequest, req.state)
+	}
+	return nil
+}
+
+func (r *Reader) grow(size int) {
+	if size <= len(r.buf) {
+		return
+	}
+	newBuf := make([]byte, size)
+	copy(newBuf, r.buf)
+	r.buf = newBuf
+}
+
+// Param returns the named path parameter, or "" if it wasn't matched.
+func (r *Request) Param(name string) string {
+	return r.Params[name]
 }
 
 func (r *Request) parse(data []byte) (int, error) {
 	totalBytesParsed := 0
 	for r.state != requestStateDone {
+		prevState := r.state
 		n, err := r.parseSingle(data[totalBytesParsed:])
 		if err != nil {
 			return 0, err
 		}
 		totalBytesParsed += n
-		if n == 0 {
+		if n == 0 && r.state == prevState {
 			break
 		}
 	}
@@ -96,6 +230,14 @@ func (r *Request) parse(data []byte) (int, error) {
 func (r *Request) parseSingle(data []byte) (int, error) {
 	switch r.state {
 	case requestStateInitialized:
+		// empty lines before the request line are skipped, RFC 9112
+		// section 2.2
+		if bytes.HasPrefix(data, []byte(crlf)) {
+			return len(crlf), nil
+		}
+		if err := r.checkRequestLine(data); err != nil {
+			return 0, err
+		}
 		requestLine, n, err := parseRequestLine(data)
 		if err != nil {
 			// something actually went wrong
@@ -105,38 +247,81 @@ func (r *Request) parseSingle(data []byte) (int, error) {
 			// just need more data
 			return 0, nil
 		}
+		r.URL, err = parseTarget(requestLine.Method, requestLine.RequestTarget)
+		if err != nil {
+			return 0, err
+		}
 		r.RequestLine = *requestLine
 		r.state = requestStateParsingHeaders
 		return n, nil
 	case requestStateParsingHeaders:
+		if err := r.checkHeaderLine(data); err != nil {
+			return 0, err
+		}
 		n, done, err := r.Headers.Parse(data)
 		if err != nil {
-			return 0, err
+			return 0, fmt.Errorf("%w: %v", ErrMalformedHeader, err)
 		}
 		if n == 0 {
 			return 0, nil
 		}
 		if done {
+			if err := r.checkFraming(); err != nil {
+				return 0, err
+			}
+			if err := r.checkHost(); err != nil {
+				return 0, err
+			}
 			r.state = requestStateParsingBody
+		} else if err := r.countHeaderLine(n); err != nil {
+			return 0, err
 		}
 		return n, nil
 	case requestStateParsingBody:
-		contentLength, err := r.Headers.Get("Content-Length")
-		if err != nil {
+		if r.chunked {
+			r.state = requestStateParsingChunkSize
+			return 0, nil
+		}
+		if r.contentLength == noContentLength {
 			r.state = requestStateDone
 			return 0, nil
 		}
-		intContentLength, err := strconv.Atoi(contentLength)
-		if len(data) > intContentLength {
-			return 0, fmt.Errorf("Body of len %d is larger than content length: %d\n", len(data), intContentLength)
+		if err := r.checkBodySize(int64(r.contentLength)); err != nil {
+			return 0, err
+		}
+		// anything past the content length belongs to the next request
+		remaining := r.contentLength - r.bodyRead
+		if len(data) > remaining {
+			data = data[:remaining]
 		}
 		r.Body = append(r.Body, data...)
-		if len(r.Body) == intContentLength {
+		r.bodyRead += len(data)
+		if r.bodyRead == r.contentLength {
 			r.state = requestStateDone
-			fmt.Println(string(data))
-			fmt.Println("Read all data from the request")
 		}
 		return len(data), nil
+	case requestStateParsingChunkSize:
+		return r.parseChunkSize(data)
+	case requestStateParsingChunkData:
+		return r.parseChunkData(data)
+	case requestStateParsingChunkDataEnd:
+		return r.parseChunkDataEnd(data)
+	case requestStateParsingTrailers:
+		if err := r.checkHeaderLine(data); err != nil {
+			return 0, err
+		}
+		n, done, err := r.Trailers.Parse(data)
+		if err != nil {
+			return 0, fmt.Errorf("%w: trailer: %v", ErrMalformedHeader, err)
+		}
+		if done {
+			r.state = requestStateDone
+		} else if n > 0 {
+			if err := r.countHeaderLine(n); err != nil {
+				return 0, err
+			}
+		}
+		return n, nil
 	case requestStateDone:
 		return 0, fmt.Errorf("error: trying to read data in a done state")
 	default:
@@ -160,26 +345,29 @@ func parseRequestLine(data []byte) (*RequestLine, int, error) {
 func requestLineFromString(str string) (*RequestLine, error) {
 	parts := strings.Split(str, " ")
 	if len(parts) != 3 {
-		return nil, fmt.Errorf("poorly formatted request-line: %s %d\n", str, len(parts))
+		return nil, fmt.Errorf("%w: %q has %d parts", ErrMalformedRequestLine, str, len(parts))
 	}
 
 	method := strings.TrimSpace(parts[0])
-	if !IsUpper(method) {
-		return nil, fmt.Errorf("Method not valid: %q\n", method)
+	if method == "" || !IsUpper(method) {
+		return nil, fmt.Errorf("%w: method not valid: %q", ErrMalformedRequestLine, method)
 	}
 	requestTarget := strings.TrimSpace(parts[1])
 	versionParts := strings.Split(parts[2], "/")
 	if len(versionParts) != 2 {
-		return nil, fmt.Errorf("Malformed HTTP version: %s\n", parts[2])
+		return nil, fmt.Errorf("%w: malformed HTTP version: %s", ErrMalformedRequestLine, parts[2])
 	}
 	httpPart := versionParts[0]
 	if httpPart != "HTTP" {
-		return nil, fmt.Errorf("Unrecognized HTTP name: %q\n", httpPart)
+		return nil, fmt.Errorf("%w: unrecognized HTTP name: %q", ErrMalformedRequestLine, httpPart)
 	}
 
 	version := versionParts[1]
+	if !isVersionNumber(version) {
+		return nil, fmt.Errorf("%w: malformed HTTP version: %s", ErrMalformedRequestLine, version)
+	}
 	if version != "1.1" {
-		return nil, fmt.Errorf("Unrecognized HTTP version: %s\n", version)
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
 	}
 
 	return &RequestLine{
@@ -197,3 +385,10 @@ func IsUpper(s string) bool {
 	}
 	return true
 }
+
+// isVersionNumber checks for the DIGIT "." DIGIT form of HTTP-version.
+func isVersionNumber(s string) bool {
+	return len(s) == 3 && s[1] == '.' &&
+		s[0] >= '0' && s[0] <= '9' &&
+		s[2] >= '0' && s[2] <= '9'
+}
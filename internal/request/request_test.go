@@ -110,9 +110,9 @@ func TestRequestLineParse(t *testing.T) {
 	r, err = RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "localhost:42069", r.Headers["host"])
-	assert.Equal(t, "curl/7.81.0", r.Headers["user-agent"])
-	assert.Equal(t, "*/*", r.Headers["accept"])
+	assert.Equal(t, []string{"localhost:42069"}, r.Headers.Values("host"))
+	assert.Equal(t, []string{"curl/7.81.0"}, r.Headers.Values("user-agent"))
+	assert.Equal(t, []string{"*/*"}, r.Headers.Values("accept"))
 
 	// Test: Malformed Header
 	reader = &chunkReader{
@@ -132,9 +132,9 @@ func TestHeadersParse(t *testing.T) {
 	r, err := RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "localhost:42069", r.Headers["host"])
-	assert.Equal(t, "curl/7.81.0", r.Headers["user-agent"])
-	assert.Equal(t, "*/*", r.Headers["accept"])
+	assert.Equal(t, []string{"localhost:42069"}, r.Headers.Values("host"))
+	assert.Equal(t, []string{"curl/7.81.0"}, r.Headers.Values("user-agent"))
+	assert.Equal(t, []string{"*/*"}, r.Headers.Values("accept"))
 
 	// Test: Empty Headers
 	reader = &chunkReader{
@@ -142,9 +142,7 @@ func TestHeadersParse(t *testing.T) {
 		numBytesPerRead: 3,
 	}
 	r, err = RequestFromReader(reader)
-	require.NoError(t, err)
-	require.NotNil(t, r)
-	assert.Empty(t, r.Headers)
+	require.ErrorIs(t, err, ErrInvalidHost)
 
 	// Test: Malformed Header
 	reader = &chunkReader{
@@ -156,13 +154,21 @@ func TestHeadersParse(t *testing.T) {
 
 	// Test: Duplicate Headers
 	reader = &chunkReader{
-		data:            "GET / HTTP/1.1\r\nHost: localhost:42069\r\nHost: duplicate:8080\r\n\r\n",
+		data:            "GET / HTTP/1.1\r\nHost: localhost:42069\r\nAccept: text/html\r\nAccept: */*\r\n\r\n",
 		numBytesPerRead: 3,
 	}
 	r, err = RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "localhost:42069, duplicate:8080", r.Headers["host"])
+	assert.Equal(t, []string{"text/html", "*/*"}, r.Headers.Values("accept"))
+
+	// Test: Duplicate Host
+	reader = &chunkReader{
+		data:            "GET / HTTP/1.1\r\nHost: localhost:42069\r\nHost: duplicate:8080\r\n\r\n",
+		numBytesPerRead: 3,
+	}
+	_, err = RequestFromReader(reader)
+	require.ErrorIs(t, err, ErrInvalidHost)
 
 	// Test: Case Insensitive Headers
 	reader = &chunkReader{
@@ -172,8 +178,8 @@ func TestHeadersParse(t *testing.T) {
 	r, err = RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "localhost:42069", r.Headers["host"])
-	assert.Equal(t, "curl/7.81.0", r.Headers["user-agent"])
+	assert.Equal(t, []string{"localhost:42069"}, r.Headers.Values("host"))
+	assert.Equal(t, []string{"curl/7.81.0"}, r.Headers.Values("user-agent"))
 
 	// Test: Missing End of Headers
 	reader = &chunkReader{
@@ -237,3 +243,428 @@ func TestBodyParse(t *testing.T) {
 	require.NotNil(t, r)
 	assert.Equal(t, "", string(r.Body))
 }
+
+func TestPipelinedRequests(t *testing.T) {
+	data := "GET /first HTTP/1.1\r\nHost: localhost:42069\r\n\r\n" +
+		"POST /second HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 5\r\n\r\nhello" +
+		"\r\n\r\nGET /third HTTP/1.1\r\nHost: localhost:42069\r\n\r\n\r\n"
+
+	for _, numBytesPerRead := range []int{1, 3, 17, len(data)} {
+		reader := NewReader(&chunkReader{
+			data:            data,
+			numBytesPerRead: numBytesPerRead,
+		})
+
+		// Test: Requests come back in order with bodies intact, and empty
+		// lines between them are skipped
+		r, err := reader.ReadRequest()
+		require.NoError(t, err)
+		assert.Equal(t, "/first", r.RequestLine.RequestTarget)
+
+		r, err = reader.ReadRequest()
+		require.NoError(t, err)
+		assert.Equal(t, "/second", r.RequestLine.RequestTarget)
+		assert.Equal(t, "hello", string(r.Body))
+
+		r, err = reader.ReadRequest()
+		require.NoError(t, err)
+		assert.Equal(t, "/third", r.RequestLine.RequestTarget)
+		assert.Equal(t, []string{"localhost:42069"}, r.Headers.Values("host"))
+
+		// Test: Clean EOF after the last request
+		_, err = reader.ReadRequest()
+		assert.ErrorIs(t, err, io.EOF)
+	}
+
+	// Test: Truncated request after a complete one
+	reader := NewReader(&chunkReader{
+		data:            "GET /first HTTP/1.1\r\nHost: localhost\r\n\r\nGET /sec",
+		numBytesPerRead: 4,
+	})
+	_, err := reader.ReadRequest()
+	require.NoError(t, err)
+	_, err = reader.ReadRequest()
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, io.EOF)
+}
+
+func TestChunkedBodyParse(t *testing.T) {
+	data := "POST /submit HTTP/1.1\r\n" +
+		"Host: localhost:42069\r\n" +
+		"Transfer-Encoding: chunked\r\n" +
+		"\r\n" +
+		"5\r\nhello\r\n" +
+		"7;name=value\r\n world!\r\n" +
+		"0\r\n" +
+		"X-Checksum: abc123\r\n" +
+		"\r\n"
+
+	// Test: Chunked body at any read size
+	for _, numBytesPerRead := range []int{1, 3, 8, len(data)} {
+		reader := &chunkReader{
+			data:            data,
+			numBytesPerRead: numBytesPerRead,
+		}
+		r, err := RequestFromReader(reader)
+		require.NoError(t, err)
+		require.NotNil(t, r)
+		assert.Equal(t, "hello world!", string(r.Body))
+		assert.Equal(t, []string{"abc123"}, r.Trailers.Values("x-checksum"))
+	}
+
+	// Test: No trailers, followed by a pipelined request
+	reader := NewReader(&chunkReader{
+		data: "POST /submit HTTP/1.1\r\nHost: localhost\r\n" +
+			"Transfer-Encoding: gzip, chunked\r\n" +
+			"\r\n" +
+			"A\r\n0123456789\r\n" +
+			"0\r\n" +
+			"\r\n" +
+			"GET /next HTTP/1.1\r\nHost: localhost\r\n\r\n",
+		numBytesPerRead: 3,
+	})
+	r, err := reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(r.Body))
+	assert.Empty(t, r.Trailers)
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/next", r.RequestLine.RequestTarget)
+
+	// Test: Invalid chunk size
+	reader = NewReader(&chunkReader{
+		data: "POST /submit HTTP/1.1\r\nHost: localhost\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"zz\r\nhello\r\n0\r\n\r\n",
+		numBytesPerRead: 3,
+	})
+	_, err = reader.ReadRequest()
+	require.Error(t, err)
+
+	// Test: Chunk longer than its size
+	reader = NewReader(&chunkReader{
+		data: "POST /submit HTTP/1.1\r\nHost: localhost\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"3\r\nhello\r\n0\r\n\r\n",
+		numBytesPerRead: 3,
+	})
+	_, err = reader.ReadRequest()
+	require.Error(t, err)
+
+	// Test: Missing terminating chunk
+	reader = NewReader(&chunkReader{
+		data: "POST /submit HTTP/1.1\r\nHost: localhost\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" +
+			"5\r\nhello\r\n",
+		numBytesPerRead: 3,
+	})
+	_, err = reader.ReadRequest()
+	require.Error(t, err)
+}
+
+func TestStreamingBody(t *testing.T) {
+	data := "POST /upload HTTP/1.1\r\n" +
+		"Host: localhost:42069\r\n" +
+		"Content-Length: 13\r\n" +
+		"\r\n" +
+		"hello world!\n" +
+		"POST /chunked HTTP/1.1\r\nHost: localhost\r\n" +
+		"Transfer-Encoding: chunked\r\n" +
+		"\r\n" +
+		"5\r\nhello\r\n7\r\n world!\r\n0\r\n\r\n" +
+		"GET /last HTTP/1.1\r\nHost: localhost\r\n\r\n"
+
+	for _, numBytesPerRead := range []int{1, 3, len(data)} {
+		reader := NewReader(&chunkReader{
+			data:            data,
+			numBytesPerRead: numBytesPerRead,
+		})
+
+		// Test: Content-Length body is streamed
+		r, err := reader.ReadStreamingRequest()
+		require.NoError(t, err)
+		assert.Nil(t, r.Body)
+		body, err := io.ReadAll(r.BodyReader)
+		require.NoError(t, err)
+		assert.Equal(t, "hello world!\n", string(body))
+		require.NoError(t, r.BodyReader.Close())
+
+		// Test: Closing an unread chunked body skips to the next request
+		r, err = reader.ReadStreamingRequest()
+		require.NoError(t, err)
+		assert.Equal(t, "/chunked", r.RequestLine.RequestTarget)
+		require.NoError(t, r.BodyReader.Close())
+
+		// Test: Request without a body
+		r, err = reader.ReadStreamingRequest()
+		require.NoError(t, err)
+		assert.Equal(t, "/last", r.RequestLine.RequestTarget)
+		body, err = io.ReadAll(r.BodyReader)
+		require.NoError(t, err)
+		assert.Empty(t, body)
+	}
+
+	// Test: Connection ends before Content-Length is reached
+	reader := NewReader(&chunkReader{
+		data: "POST /upload HTTP/1.1\r\nHost: localhost\r\n" +
+			"Content-Length: 20\r\n" +
+			"\r\n" +
+			"partial content",
+		numBytesPerRead: 3,
+	})
+	r, err := reader.ReadStreamingRequest()
+	require.NoError(t, err)
+	_, err = io.ReadAll(r.BodyReader)
+	require.Error(t, err)
+}
+
+func TestRequestLimits(t *testing.T) {
+	limits := Limits{
+		MaxRequestLineBytes: 32,
+		MaxHeaderBytes:      64,
+		MaxHeaderCount:      3,
+		MaxBodyBytes:        10,
+	}
+	read := func(data string) error {
+		reader := NewReader(&chunkReader{data: data, numBytesPerRead: 3})
+		reader.Limits = limits
+		_, err := reader.ReadRequest()
+		return err
+	}
+
+	// Test: Within every limit
+	err := read("POST /submit HTTP/1.1\r\nHost: localhost:42069\r\nContent-Length: 10\r\n\r\n0123456789")
+	require.NoError(t, err)
+
+	// Test: Request line too long, even before its CRLF arrives
+	err = read("GET /" + strings.Repeat("a", 64))
+	assert.ErrorIs(t, err, ErrRequestLineTooLong)
+
+	// Test: Too many header bytes
+	err = read("GET / HTTP/1.1\r\nHost: localhost\r\nX-Big: " + strings.Repeat("a", 64) + "\r\n\r\n")
+	assert.ErrorIs(t, err, ErrHeadersTooLarge)
+
+	// Test: Too many header fields
+	err = read("GET / HTTP/1.1\r\nHost: localhost\r\nA: 1\r\nB: 2\r\nC: 3\r\nD: 4\r\n\r\n")
+	assert.ErrorIs(t, err, ErrHeadersTooLarge)
+
+	// Test: Content-Length over the body limit
+	err = read("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 11\r\n\r\n01234567890")
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+
+	// Test: Chunked body grows over the body limit
+	err = read("POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n6\r\nhello \r\n6\r\nworld!\r\n0\r\n\r\n")
+	assert.ErrorIs(t, err, ErrBodyTooLarge)
+
+	// Test: Trailers count towards the header limits
+	err = read("POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n0\r\nA: 1\r\nB: 2\r\nC: 3\r\n\r\n")
+	assert.ErrorIs(t, err, ErrHeadersTooLarge)
+}
+
+func TestParseErrorKinds(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		want error
+	}{
+		{"missing method", " / HTTP/1.1\r\nHost: localhost\r\n\r\n", ErrMalformedRequestLine},
+		{"lowercase method", "get / HTTP/1.1\r\nHost: localhost\r\n\r\n", ErrMalformedRequestLine},
+		{"bad protocol name", "GET / HTTPS/1.1\r\n\r\n", ErrMalformedRequestLine},
+		{"garbage version", "GET / HTTP/one\r\n\r\n", ErrMalformedRequestLine},
+		{"old version", "GET / HTTP/1.0\r\n\r\n", ErrUnsupportedVersion},
+		{"future version", "GET / HTTP/2.0\r\n\r\n", ErrUnsupportedVersion},
+		{"header without colon", "GET / HTTP/1.1\r\nHost localhost\r\n\r\n", ErrMalformedHeader},
+		{"bad trailer", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n0\r\nBad Trailer\r\n\r\n", ErrMalformedHeader},
+		{"bad content length", "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: ten\r\n\r\n", ErrBodyLengthMismatch},
+		{"short body", "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 10\r\n\r\nabc", ErrBodyLengthMismatch},
+		{"bad chunk size", "POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\nxyz\r\n", ErrBodyLengthMismatch},
+		{"cut off headers", "GET / HTTP/1.1\r\nHost: localhost", ErrIncompleteRequest},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := RequestFromReader(&chunkReader{data: c.data, numBytesPerRead: 3})
+			assert.ErrorIs(t, err, c.want)
+		})
+	}
+}
+
+func TestSmugglingPayloads(t *testing.T) {
+	const post = "POST / HTTP/1.1\r\nHost: localhost\r\n"
+	cases := []struct {
+		name string
+		data string
+		want error
+	}{
+		// CL.TE and TE.CL: front and back end pick different framings
+		{"content length then chunked", post + "Content-Length: 6\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\nG", ErrAmbiguousFraming},
+		{"chunked then content length", post + "Transfer-Encoding: chunked\r\nContent-Length: 3\r\n\r\n8\r\nSMUGGLED\r\n0\r\n\r\n", ErrAmbiguousFraming},
+		{"folded chunked with content length", post + "Transfer-Encoding:\r\n chunked\r\nContent-Length: 3\r\n\r\n", ErrAmbiguousFraming},
+		// TE.TE: obfuscated codings one side doesn't recognise
+		{"unknown coding", post + "Transfer-Encoding: xchunked\r\n\r\n", ErrAmbiguousFraming},
+		{"chunked not last", post + "Transfer-Encoding: chunked, identity\r\n\r\n", ErrAmbiguousFraming},
+		{"chunked twice", post + "Transfer-Encoding: chunked\r\nTransfer-Encoding: chunked\r\n\r\n", ErrAmbiguousFraming},
+		{"empty coding", post + "Transfer-Encoding: \r\n\r\n", ErrAmbiguousFraming},
+		{"space before colon", post + "Transfer-Encoding : chunked\r\n\r\n", ErrMalformedHeader},
+		{"tab before colon", post + "Transfer-Encoding\t: chunked\r\n\r\n", ErrMalformedHeader},
+		{"tab before length colon", post + "Content-Length\t: 3\r\n\r\nabc", ErrMalformedHeader},
+		{"non-ASCII name", post + "Transfer-Encodin\u0261: chunked\r\n\r\n", ErrMalformedHeader},
+		// CL.CL: lengths that disagree
+		{"conflicting lengths", post + "Content-Length: 3\r\nContent-Length: 5\r\n\r\nabcde", ErrAmbiguousFraming},
+		{"conflicting length list", post + "Content-Length: 3, 5\r\n\r\nabcde", ErrAmbiguousFraming},
+		{"signed length", post + "Content-Length: +3\r\n\r\nabc", ErrBodyLengthMismatch},
+		{"negative length", post + "Content-Length: -1\r\n\r\n", ErrBodyLengthMismatch},
+		{"hex length", post + "Content-Length: 0x3\r\n\r\nabc", ErrBodyLengthMismatch},
+		{"spaced length", post + "Content-Length: 1 2\r\n\r\n", ErrBodyLengthMismatch},
+		{"empty length", post + "Content-Length: \r\n\r\n", ErrBodyLengthMismatch},
+		// Line ending tricks that hide one header inside another
+		{"bare LF in value", post + "X-Foo: a\nTransfer-Encoding: chunked\r\n\r\n", ErrMalformedHeader},
+		{"bare CR in value", post + "X-Foo: a\rContent-Length: 5\r\n\r\n", ErrMalformedHeader},
+		{"NUL in value", post + "X-Foo: a\x00b\r\n\r\n", ErrMalformedHeader},
+		{"control in value", post + "X-Foo: a\x1bb\r\n\r\n", ErrMalformedHeader},
+		{"DEL in value", post + "X-Foo: a\x7fb\r\n\r\n", ErrMalformedHeader},
+		{"fold before first header", "POST / HTTP/1.1\r\n Transfer-Encoding: chunked\r\n\r\n", ErrMalformedHeader},
+		{"empty header name", post + ": chunked\r\n\r\n", ErrMalformedHeader},
+		// Chunk framing
+		{"oversized chunk", post + "Transfer-Encoding: chunked\r\n\r\nfffffffffffffffffff\r\n", ErrBodyLengthMismatch},
+		{"hex prefixed chunk", post + "Transfer-Encoding: chunked\r\n\r\n0x5\r\nhello\r\n0\r\n\r\n", ErrBodyLengthMismatch},
+		{"chunk longer than size", post + "Transfer-Encoding: chunked\r\n\r\n3\r\nhello\r\n0\r\n\r\n", ErrBodyLengthMismatch},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := RequestFromReader(&chunkReader{data: c.data, numBytesPerRead: 3})
+			assert.ErrorIs(t, err, c.want)
+		})
+	}
+
+	accepted := []struct {
+		name   string
+		data   string
+		body   string
+		header string
+		value  string
+	}{
+		{"repeated equal lengths", post + "Content-Length: 3\r\nContent-Length: 3\r\n\r\nabc", "abc", "Content-Length", "3, 3"},
+		{"equal length list", post + "Content-Length: 3, 3\r\n\r\nabc", "abc", "Content-Length", "3, 3"},
+		{"tab around value", post + "Transfer-Encoding:\tchunked\t\r\n\r\n3\r\nabc\r\n0\r\n\r\n", "abc", "Transfer-Encoding", "chunked"},
+		{"folded value", post + "X-Long: part one\r\n\tpart two\r\nContent-Length: 0\r\n\r\n", "", "X-Long", "part one part two"},
+		{"obs-text value", post + "X-Name: caf\xe9\r\nContent-Length: 0\r\n\r\n", "", "X-Name", "caf\xe9"},
+	}
+	for _, c := range accepted {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := RequestFromReader(&chunkReader{data: c.data, numBytesPerRead: 3})
+			require.NoError(t, err)
+			assert.Equal(t, c.body, string(r.Body))
+			value, err := r.Headers.Get(c.header)
+			require.NoError(t, err)
+			assert.Equal(t, c.value, value)
+		})
+	}
+}
+
+func TestHost(t *testing.T) {
+	cases := []struct {
+		name   string
+		data   string
+		host   string
+		origin string
+		err    error
+	}{
+		{"host header", "GET /a?b HTTP/1.1\r\nHost: example.com:8080\r\n\r\n", "example.com:8080", "/a?b", nil},
+		{"ipv6 host", "GET / HTTP/1.1\r\nHost: [::1]:8080\r\n\r\n", "[::1]:8080", "/", nil},
+		{"empty host", "GET / HTTP/1.1\r\nHost: \r\n\r\n", "", "/", nil},
+		{"absolute form", "GET http://tools.internal/a?b HTTP/1.1\r\nHost: other\r\n\r\n", "tools.internal", "/a?b", nil},
+		{"absolute form without path", "GET https://tools.internal HTTP/1.1\r\nHost: tools.internal\r\n\r\n", "tools.internal", "/", nil},
+		{"absolute form with query only", "GET http://tools.internal?x=1 HTTP/1.1\r\nHost: tools.internal\r\n\r\n", "tools.internal", "/?x=1", nil},
+		{"authority form", "CONNECT tools.internal:443 HTTP/1.1\r\nHost: other\r\n\r\n", "tools.internal:443", "tools.internal:443", nil},
+		{"missing host", "GET / HTTP/1.1\r\nAccept: */*\r\n\r\n", "", "", ErrInvalidHost},
+		{"duplicate host", "GET / HTTP/1.1\r\nHost: a\r\nhost: b\r\n\r\n", "", "", ErrInvalidHost},
+		{"host list", "GET / HTTP/1.1\r\nHost: a, b\r\n\r\n", "", "", ErrInvalidHost},
+		{"host with path", "GET / HTTP/1.1\r\nHost: a/b\r\n\r\n", "", "", ErrInvalidHost},
+		{"host with userinfo", "GET / HTTP/1.1\r\nHost: user@a\r\n\r\n", "", "", ErrInvalidHost},
+		{"absolute form without host header", "GET http://a/ HTTP/1.1\r\n\r\n", "", "", ErrInvalidHost},
+		{"absolute form with userinfo", "GET http://user@a/ HTTP/1.1\r\nHost: a\r\n\r\n", "", "", ErrInvalidTarget},
+		{"absolute form without authority", "GET http:///a HTTP/1.1\r\nHost: a\r\n\r\n", "", "", ErrInvalidTarget},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := RequestFromReader(&chunkReader{data: c.data, numBytesPerRead: 3})
+			if c.err != nil {
+				assert.ErrorIs(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.host, r.Host)
+			assert.Equal(t, c.origin, r.URL.RequestURI())
+		})
+	}
+}
+
+func TestRequestTarget(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		target string
+		err    bool
+		want   URL
+	}{
+		{"origin form", "GET", "/search?q=go&q=http#top", false, URL{Form: OriginForm, Path: "/search", RawPath: "/search", RawQuery: "q=go&q=http", Fragment: "top"}},
+		{"absolute form", "GET", "HTTP://example.com:8080/a/b?x", false, URL{Form: AbsoluteForm, Scheme: "http", Host: "example.com:8080", Path: "/a/b", RawPath: "/a/b", RawQuery: "x"}},
+		{"authority form", "CONNECT", "example.com:443", false, URL{Form: AuthorityForm, Host: "example.com:443"}},
+		{"authority form ipv6", "CONNECT", "[::1]:443", false, URL{Form: AuthorityForm, Host: "[::1]:443"}},
+		{"asterisk form", "OPTIONS", "*", false, URL{Form: AsteriskForm, Path: "*", RawPath: "*"}},
+		{"percent decoding", "GET", "/caf%C3%A9/a%20b", false, URL{Form: OriginForm, Path: "/caf\u00e9/a b", RawPath: "/caf%C3%A9/a%20b"}},
+		{"encoded slash", "GET", "/files/a%2Fb", false, URL{Form: OriginForm, Path: "/files/a/b", RawPath: "/files/a%2Fb"}},
+		{"dot segments", "GET", "/a/./b/../c", false, URL{Form: OriginForm, Path: "/a/c", RawPath: "/a/c"}},
+		{"trailing dot dot", "GET", "/a/b/..", false, URL{Form: OriginForm, Path: "/a/", RawPath: "/a/"}},
+		{"dot dot above root", "GET", "/../../etc/passwd", false, URL{Form: OriginForm, Path: "/etc/passwd", RawPath: "/etc/passwd"}},
+		{"encoded dot dot", "GET", "/static/%2e%2e/%2E%2E/secret", false, URL{Form: OriginForm, Path: "/secret", RawPath: "/secret"}},
+		{"empty", "GET", "", true, URL{}},
+		{"relative", "GET", "index.html", true, URL{}},
+		{"space", "GET", "/a\x7fb", true, URL{}},
+		{"non-ascii", "GET", "/caf\u00e9", true, URL{}},
+		{"bad escape", "GET", "/a%zz", true, URL{}},
+		{"truncated escape", "GET", "/a%2", true, URL{}},
+		{"bad escape in query", "GET", "/a?b=%", true, URL{}},
+		{"encoded nul", "GET", "/a%00b", true, URL{}},
+		{"asterisk on get", "GET", "*", true, URL{}},
+		{"connect without port", "CONNECT", "example.com", true, URL{}},
+		{"connect with path", "CONNECT", "/a", true, URL{}},
+		{"ftp scheme", "GET", "ftp://example.com/", true, URL{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			u, err := parseTarget(c.method, c.target)
+			if c.err {
+				assert.ErrorIs(t, err, ErrInvalidTarget)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.want, *u)
+		})
+	}
+
+	// Test: A bad target fails the whole request
+	_, err := RequestFromReader(&chunkReader{data: "GET /a%zz HTTP/1.1\r\nHost: localhost\r\n\r\n", numBytesPerRead: 3})
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+}
+
+func TestQuery(t *testing.T) {
+	r, err := RequestFromReader(&chunkReader{data: "GET /search?tag=a&tag=b+c&q=caf%C3%A9&flag&&=x HTTP/1.1\r\nHost: localhost\r\n\r\n", numBytesPerRead: 3})
+	require.NoError(t, err)
+	query := r.Query()
+	assert.Equal(t, []string{"a", "b c"}, query["tag"])
+	assert.Equal(t, "a", query.Get("tag"))
+	assert.Equal(t, "caf\u00e9", query.Get("q"))
+	assert.True(t, query.Has("flag"))
+	assert.Equal(t, "", query.Get("flag"))
+	assert.Equal(t, "x", query.Get(""))
+	assert.False(t, query.Has("missing"))
+
+	// Test: Pairs that don't decode are skipped and reported
+	values, err := ParseQuery("a=1&b=%2&c=3")
+	assert.Error(t, err)
+	assert.Equal(t, Values{"a": {"1"}, "c": {"3"}}, values)
+}
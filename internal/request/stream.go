@@ -0,0 +1,77 @@
+package request
+
+import (
+	"fmt"
+	"io"
+)
+
+// streamBufferSize is the read size used once we are streaming a body,
+// the tiny header buffer would mean a syscall every few bytes.
+const streamBufferSize = 32 * 1024
+
+// ReadStreamingRequest returns as soon as the request line and headers
+// are parsed. The body is left on the connection and is pulled on demand
+// through Request.BodyReader, which stops at the end of the Content-Length
+// or chunked framing. The body has to be read or closed before the next
+// request can be read.
+func (r *Reader) ReadStreamingRequest() (*Request, error) {
+	req, err := r.ReadHeaders()
+	if err != nil {
+		return nil, err
+	}
+	r.StreamBody(req)
+	return req, nil
+}
+
+// StreamBody sets up Request.BodyReader for a request returned by
+// ReadHeaders.
+func (r *Reader) StreamBody(req *Request) {
+	r.grow(streamBufferSize)
+	// parsing may have run past the headers into the body already
+	req.BodyReader = &bodyReader{
+		reader:  r,
+		req:     req,
+		pending: req.Body,
+	}
+	req.Body = nil
+}
+
+type bodyReader struct {
+	reader  *Reader
+	req     *Request
+	pending []byte
+	closed  bool
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, fmt.Errorf("read on closed body")
+	}
+	for len(b.pending) == 0 {
+		if b.req.state == requestStateDone {
+			return 0, io.EOF
+		}
+		if err := b.reader.advance(b.req); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		b.pending = b.req.Body
+		b.req.Body = nil
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+// Close discards the rest of the body so the connection is positioned at
+// the next request.
+func (b *bodyReader) Close() error {
+	if b.closed {
+		return nil
+	}
+	_, err := io.Copy(io.Discard, b)
+	b.closed = true
+	return err
+}
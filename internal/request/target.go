@@ -0,0 +1,229 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidTarget is for request targets that aren't in any of the four
+// forms RFC 9112 section 3.2 allows, or don't fit the method.
+var ErrInvalidTarget = errors.New("invalid request target")
+
+// TargetForm is which of the request-target forms a request used.
+type TargetForm int
+
+const (
+	// OriginForm is a path and query, as in GET /where?q=now.
+	OriginForm TargetForm = iota
+	// AbsoluteForm is a full URI, as sent to proxies.
+	AbsoluteForm
+	// AuthorityForm is host:port, only used by CONNECT.
+	AuthorityForm
+	// AsteriskForm is *, only used by server-wide OPTIONS.
+	AsteriskForm
+)
+
+// URL is the parsed request target.
+type URL struct {
+	Form TargetForm
+	// Scheme is http or https for absolute-form targets, else empty.
+	Scheme string
+	// Host is the authority of absolute-form and authority-form targets.
+	Host string
+	// Path is percent-decoded with dot segments removed. It is empty for
+	// authority-form and "*" for asterisk-form targets.
+	Path string
+	// RawPath is Path before decoding, so still has any %2F in it.
+	RawPath  string
+	RawQuery string
+	Fragment string
+}
+
+// Query parses the query string, skipping pairs that don't decode.
+func (u *URL) Query() Values {
+	values, _ := ParseQuery(u.RawQuery)
+	return values
+}
+
+// RequestURI is the target in origin form, the encoded path and query.
+func (u *URL) RequestURI() string {
+	if u.Form == AuthorityForm {
+		return u.Host
+	}
+	uri := u.RawPath
+	if u.RawQuery != "" {
+		uri += "?" + u.RawQuery
+	}
+	return uri
+}
+
+// Query is a shortcut for r.URL.Query().
+func (r *Request) Query() Values {
+	return r.URL.Query()
+}
+
+// parseTarget works out the form of a request target and splits it up.
+func parseTarget(method, target string) (*URL, error) {
+	if target == "" {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidTarget)
+	}
+	if i := strings.IndexFunc(target, func(c rune) bool { return !isTargetChar(c) }); i != -1 {
+		return nil, fmt.Errorf("%w: character %q not allowed in %q", ErrInvalidTarget, target[i], target)
+	}
+
+	switch {
+	case target == "*":
+		if method != "OPTIONS" {
+			return nil, fmt.Errorf("%w: * is only for OPTIONS, not %s", ErrInvalidTarget, method)
+		}
+		return &URL{Form: AsteriskForm, Path: "*", RawPath: "*"}, nil
+	case method == "CONNECT":
+		return parseAuthorityForm(target)
+	case strings.HasPrefix(target, "/"):
+		u := &URL{Form: OriginForm}
+		return u, u.setPathAndQuery(target)
+	case strings.Contains(target, "://"):
+		return parseAbsoluteForm(target)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrInvalidTarget, target)
+}
+
+func parseAuthorityForm(target string) (*URL, error) {
+	host, port, ok := strings.Cut(target, ":")
+	if strings.HasPrefix(target, "[") {
+		end := strings.Index(target, "]")
+		if end == -1 {
+			return nil, fmt.Errorf("%w: CONNECT needs host:port, got %q", ErrInvalidTarget, target)
+		}
+		host = target[:end+1]
+		port, ok = strings.CutPrefix(target[end+1:], ":")
+	}
+	if !ok || host == "" || port == "" || strings.Trim(port, "0123456789") != "" || !isValidHost(host) {
+		return nil, fmt.Errorf("%w: CONNECT needs host:port, got %q", ErrInvalidTarget, target)
+	}
+	return &URL{Form: AuthorityForm, Host: target}, nil
+}
+
+func parseAbsoluteForm(target string) (*URL, error) {
+	scheme, rest, _ := strings.Cut(target, "://")
+	scheme = strings.ToLower(scheme)
+	if scheme != "http" && scheme != "https" {
+		return nil, fmt.Errorf("%w: unsupported scheme %q", ErrInvalidTarget, scheme)
+	}
+	end := strings.IndexAny(rest, "/?#")
+	if end == -1 {
+		end = len(rest)
+	}
+	u := &URL{Form: AbsoluteForm, Scheme: scheme, Host: rest[:end]}
+	if u.Host == "" || !isValidHost(u.Host) {
+		return nil, fmt.Errorf("%w: bad authority in %q", ErrInvalidTarget, target)
+	}
+	pathAndQuery := rest[end:]
+	if !strings.HasPrefix(pathAndQuery, "/") {
+		pathAndQuery = "/" + pathAndQuery
+	}
+	return u, u.setPathAndQuery(pathAndQuery)
+}
+
+// setPathAndQuery splits off the query and fragment, then decodes and
+// normalizes the path.
+func (u *URL) setPathAndQuery(target string) error {
+	target, u.Fragment, _ = strings.Cut(target, "#")
+	rawPath, rawQuery, _ := strings.Cut(target, "?")
+	u.RawQuery = rawQuery
+	if _, err := unescape(rawQuery, false); err != nil {
+		return fmt.Errorf("%w: query: %v", ErrInvalidTarget, err)
+	}
+	var err error
+	u.Path, u.RawPath, err = normalizePath(rawPath)
+	return err
+}
+
+// normalizePath decodes each segment and removes "." and ".." segments
+// as in RFC 3986 section 5.2.4. Dots are matched after decoding so %2e%2e
+// can't be used to climb out of a directory, while an encoded slash
+// stays inside its segment. ".." never climbs above the root.
+func normalizePath(rawPath string) (string, string, error) {
+	var decoded, raw []string
+	segments := strings.Split(strings.TrimPrefix(rawPath, "/"), "/")
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		value, err := unescape(segment, false)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: path: %v", ErrInvalidTarget, err)
+		}
+		if strings.IndexByte(value, 0) != -1 {
+			return "", "", fmt.Errorf("%w: path: NUL byte", ErrInvalidTarget)
+		}
+		switch value {
+		case ".":
+		case "..":
+			if len(decoded) > 0 {
+				decoded = decoded[:len(decoded)-1]
+				raw = raw[:len(raw)-1]
+			}
+		default:
+			decoded = append(decoded, value)
+			raw = append(raw, segment)
+			continue
+		}
+		if last {
+			// keep the trailing slash of "/a/." and "/a/b/.."
+			decoded = append(decoded, "")
+			raw = append(raw, "")
+		}
+	}
+	return "/" + strings.Join(decoded, "/"), "/" + strings.Join(raw, "/"), nil
+}
+
+// unescape decodes %XX escapes, and + as a space when plusIsSpace is set
+// as it is in form-encoded queries.
+func unescape(s string, plusIsSpace bool) (string, error) {
+	if !strings.ContainsAny(s, "%+") {
+		return s, nil
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				end := min(i+3, len(s))
+				return "", fmt.Errorf("bad escape %q", s[i:end])
+			}
+			b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+		case c == '+' && plusIsSpace:
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// isTargetChar allows the characters RFC 3986 uses in URIs: unreserved,
+// reserved and % for escapes. Everything else has to be percent-encoded.
+func isTargetChar(c rune) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.ContainsRune("-._~:/?#[]@!$&'()*+,;=%", c)
+}
@@ -0,0 +1,138 @@
+package response
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+
+	"MyOwnHTTP/internal/headers"
+)
+
+// DefaultBodyBufferSize is how much a BodyWriter holds back before it
+// gives up on sending a Content-Length.
+const DefaultBodyBufferSize = 4096
+
+// ErrBodyWriterClosed is returned for writes after Close.
+var ErrBodyWriterClosed = errors.New("body writer already closed")
+
+// BodyWriter lets a handler write a body in pieces, as an io.Writer. A
+// body that fits in the buffer by Close goes out with a Content-Length;
+// a longer one, or one flushed before Close, switches to chunked. Close
+// has to be called to finish the response.
+type BodyWriter struct {
+	w          *Writer
+	statusCode StatusCode
+	headers    *headers.Headers
+	buf        bytes.Buffer
+	size       int
+	// chunked is set once the status line and headers have gone out
+	chunked bool
+	closed  bool
+	err     error
+}
+
+// NewBodyWriter starts a response with the given status and headers, which
+// are held back until the body shows how it has to be framed. Any
+// Content-Length or Transfer-Encoding in h is replaced.
+func NewBodyWriter(w *Writer, statusCode StatusCode, h *headers.Headers) *BodyWriter {
+	return NewBodyWriterWithSize(w, statusCode, h, DefaultBodyBufferSize)
+}
+
+// NewBodyWriterWithSize is NewBodyWriter with a buffer of size bytes
+// instead of DefaultBodyBufferSize.
+func NewBodyWriterWithSize(w *Writer, statusCode StatusCode, h *headers.Headers, size int) *BodyWriter {
+	if h == nil {
+		h = headers.NewHeaders()
+	}
+	if size <= 0 {
+		size = DefaultBodyBufferSize
+	}
+	h.Del("Content-Length")
+	h.Del("Transfer-Encoding")
+	return &BodyWriter{w: w, statusCode: statusCode, headers: h, size: size}
+}
+
+func (b *BodyWriter) Write(p []byte) (int, error) {
+	if b.closed {
+		return 0, ErrBodyWriterClosed
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	b.buf.Write(p)
+	if b.buf.Len() > b.size {
+		if err := b.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends what has been written so far as a chunk, for bodies like
+// server-sent events that the client reads as they come.
+func (b *BodyWriter) Flush() error {
+	if b.closed {
+		return ErrBodyWriterClosed
+	}
+	if b.err != nil {
+		return b.err
+	}
+	return b.flush()
+}
+
+// Close finishes the response. Calling it again does nothing.
+func (b *BodyWriter) Close() error {
+	if b.closed {
+		return b.err
+	}
+	b.closed = true
+	if b.err != nil {
+		return b.err
+	}
+	if !b.chunked {
+		b.err = b.writeWhole()
+		return b.err
+	}
+	if err := b.flush(); err != nil {
+		return err
+	}
+	_, b.err = b.w.WriteChunkedBodyDone(nil)
+	return b.err
+}
+
+// writeWhole sends a body that fit in the buffer with its length.
+func (b *BodyWriter) writeWhole() error {
+	if !bodyless(b.statusCode) {
+		b.headers.Set("Content-Length", strconv.Itoa(b.buf.Len()))
+	}
+	if err := b.w.WriteStatusLine(b.statusCode); err != nil {
+		return err
+	}
+	if err := b.w.WriteHeaders(b.headers); err != nil {
+		return err
+	}
+	_, err := b.w.WriteBody(b.buf.Bytes())
+	return err
+}
+
+// flush sends the buffer as a chunk, sending the headers first if they
+// haven't gone out yet.
+func (b *BodyWriter) flush() error {
+	if !b.chunked {
+		b.chunked = true
+		b.headers.Set("Transfer-Encoding", "chunked")
+		if b.err = b.w.WriteStatusLine(b.statusCode); b.err != nil {
+			return b.err
+		}
+		if b.err = b.w.WriteHeaders(b.headers); b.err != nil {
+			return b.err
+		}
+	}
+	// an empty chunk would end the body
+	if b.buf.Len() == 0 {
+		return nil
+	}
+	_, b.err = b.w.WriteChunkedBody(b.buf.Bytes())
+	b.buf.Reset()
+	return b.err
+}
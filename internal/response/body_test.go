@@ -0,0 +1,110 @@
+package response
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"MyOwnHTTP/internal/headers"
+)
+
+func readResponse(t *testing.T, buf *bytes.Buffer) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.ReadResponse(bufio.NewReader(buf), nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestBodyWriter(t *testing.T) {
+	cases := []struct {
+		name          string
+		writes        []string
+		contentLength int64
+		chunked       bool
+	}{
+		{"fits the buffer", []string{"hello ", "world"}, 11, false},
+		{"empty", nil, 0, false},
+		{"exactly the buffer", []string{"0123456789abcdef"}, 16, false},
+		{"outgrows the buffer", []string{"0123456789", "abcdefghij", "klm"}, -1, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			w.KeepAlive = true
+			h := headers.NewHeaders()
+			h.Set("Content-Type", "text/plain")
+			h.Set("Content-Length", "999")
+			b := NewBodyWriterWithSize(w, StatusOK, h, 16)
+			for _, s := range c.writes {
+				n, err := io.WriteString(b, s)
+				require.NoError(t, err)
+				assert.Equal(t, len(s), n)
+			}
+			require.NoError(t, b.Close())
+			assert.Equal(t, Done, w.WriterState)
+
+			// Test: Framing picked from how much was written
+			resp, body := readResponse(t, &buf)
+			assert.Equal(t, strings.Join(c.writes, ""), body)
+			assert.Equal(t, c.contentLength, resp.ContentLength)
+			assert.Equal(t, c.chunked, len(resp.TransferEncoding) > 0)
+			assert.False(t, resp.Close)
+		})
+	}
+}
+
+func TestBodyWriterFlush(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.KeepAlive = true
+	b := NewBodyWriter(w, StatusOK, nil)
+
+	// Test: Flush sends the headers and what was written so far
+	io.WriteString(b, "data: one\n\n")
+	require.NoError(t, b.Flush())
+	assert.Contains(t, buf.String(), "Transfer-Encoding: chunked\r\n")
+	assert.True(t, strings.HasSuffix(buf.String(), "b\r\ndata: one\n\n\r\n"))
+
+	// Test: Flushing with nothing new doesn't end the body
+	require.NoError(t, b.Flush())
+	assert.Equal(t, ReadyForBody, w.WriterState)
+
+	io.WriteString(b, "data: two\n\n")
+	require.NoError(t, b.Close())
+	resp, body := readResponse(t, &buf)
+	assert.Equal(t, []string{"chunked"}, resp.TransferEncoding)
+	assert.Equal(t, "data: one\n\ndata: two\n\n", body)
+
+	// Test: Writes after Close fail
+	_, err := b.Write([]byte("late"))
+	assert.ErrorIs(t, err, ErrBodyWriterClosed)
+	assert.NoError(t, b.Close())
+}
+
+func TestBodyWriterCompressed(t *testing.T) {
+	for _, size := range []int{DefaultBodyBufferSize, 64} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.KeepAlive = true
+		w.Compression = &Compression{Encoding: "gzip", MinSize: 100}
+		h := headers.NewHeaders()
+		h.Set("Content-Type", "text/plain")
+		b := NewBodyWriterWithSize(w, StatusOK, h, size)
+		io.WriteString(b, compressibleText)
+		require.NoError(t, b.Close())
+
+		// Test: Buffered and chunked bodies both get compressed
+		resp, body := readResponse(t, &buf)
+		assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, compressibleText, decompress(t, "gzip", []byte(body)))
+	}
+}
@@ -0,0 +1,188 @@
+package response
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"MyOwnHTTP/internal/headers"
+)
+
+// Compression tells a Writer to compress response bodies that are worth
+// it. The server's Compress middleware sets it from Accept-Encoding.
+type Compression struct {
+	// Encoding is the content coding to use, gzip or deflate. When empty
+	// nothing is compressed, but responses still get Vary so caches know
+	// other clients may get them compressed.
+	Encoding string
+	// MinSize is the smallest Content-Length worth compressing. Chunked
+	// bodies are compressed whatever their size.
+	MinSize int
+}
+
+// Encodings are the content codings Compression supports, most
+// preferred first.
+var Encodings = []string{"gzip", "deflate"}
+
+// compressibleTypes are the non-text media types that shrink well.
+// Anything not listed, such as video/mp4 or image/png, is already
+// compressed or close to it.
+var compressibleTypes = []string{
+	"application/javascript",
+	"application/json",
+	"application/wasm",
+	"application/xml",
+	"image/svg+xml",
+}
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func newCompressor(encoding string, dst io.Writer) compressor {
+	if encoding == "deflate" {
+		// the deflate coding is zlib-wrapped, RFC 9110 section 8.4.1.2
+		return zlib.NewWriter(dst)
+	}
+	return gzip.NewWriter(dst)
+}
+
+// startCompression decides whether the response is compressed and sets
+// up the headers for it. It returns true when the headers have to wait
+// for the body.
+func (w *Writer) startCompression(h *headers.Headers) bool {
+	if w.Compression == nil || !compressible(w.StatusCode, h) {
+		return false
+	}
+	addVary(h, "Accept-Encoding")
+	encoding := w.Compression.Encoding
+	if !slices.Contains(Encodings, encoding) {
+		return false
+	}
+	if te, err := h.Get("Transfer-Encoding"); err == nil && strings.Contains(strings.ToLower(te), "chunked") {
+		setEncoded(h, encoding)
+		w.encoder = newCompressor(encoding, chunkWriter{w.Buffer})
+		return false
+	}
+	length, err := h.Get("Content-Length")
+	if err != nil {
+		return false
+	}
+	n, err := strconv.Atoi(length)
+	return err == nil && n >= w.Compression.MinSize
+}
+
+// compressible skips bodyless and partial responses, ones that are
+// already encoded and media types that wouldn't shrink.
+func compressible(statusCode StatusCode, h *headers.Headers) bool {
+	if bodyless(statusCode) || statusCode == StatusPartialContent {
+		return false
+	}
+	if h.Has("Content-Encoding") || h.Has("Content-Range") {
+		return false
+	}
+	contentType, err := h.Get("Content-Type")
+	if err != nil {
+		return false
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml") ||
+		slices.Contains(compressibleTypes, mediaType)
+}
+
+// writeCompressedBody sends the held back headers with p compressed, or
+// as it is if compressing doesn't make it smaller.
+func (w *Writer) writeCompressedBody(p []byte) (int, error) {
+	h := w.pending
+	w.pending = nil
+	var buf bytes.Buffer
+	encoder := newCompressor(w.Compression.Encoding, &buf)
+	if _, err := encoder.Write(p); err != nil {
+		return 0, err
+	}
+	if err := encoder.Close(); err != nil {
+		return 0, err
+	}
+	body := p
+	if buf.Len() < len(p) {
+		body = buf.Bytes()
+		setEncoded(h, w.Compression.Encoding)
+		h.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	if err := w.writeHeaderSection(h); err != nil {
+		return 0, err
+	}
+	return w.Buffer.Write(body)
+}
+
+// streamCompressedBody compresses a body of unknown compressed length,
+// so it goes out chunked instead of with its Content-Length.
+func (w *Writer) streamCompressedBody(r io.Reader) (int64, error) {
+	h := w.pending
+	w.pending = nil
+	setEncoded(h, w.Compression.Encoding)
+	h.Del("Content-Length")
+	h.Set("Transfer-Encoding", "chunked")
+	if err := w.writeHeaderSection(h); err != nil {
+		return 0, err
+	}
+	encoder := newCompressor(w.Compression.Encoding, chunkWriter{w.Buffer})
+	n, err := io.Copy(encoder, r)
+	if err != nil {
+		return n, err
+	}
+	if err := encoder.Close(); err != nil {
+		return n, err
+	}
+	_, err = w.Buffer.Write([]byte("0\r\n\r\n"))
+	return n, err
+}
+
+// setEncoded marks the headers for a compressed body. A strong ETag
+// becomes weak since the bytes sent are no longer the ones it names.
+func setEncoded(h *headers.Headers, encoding string) {
+	h.Set("Content-Encoding", encoding)
+	if etag, err := h.Get("ETag"); err == nil && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+}
+
+// addVary adds a field name to Vary unless it's already covered.
+func addVary(h *headers.Headers, name string) {
+	vary, err := h.Get("Vary")
+	if err != nil {
+		h.Set("Vary", name)
+		return
+	}
+	for field := range strings.SplitSeq(vary, ",") {
+		field = strings.TrimSpace(field)
+		if field == "*" || strings.EqualFold(field, name) {
+			return
+		}
+	}
+	h.Set("Vary", vary+", "+name)
+}
+
+// chunkWriter writes each write as one chunk of a chunked body.
+type chunkWriter struct {
+	w io.Writer
+}
+
+func (c chunkWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if _, err := fmt.Fprintf(c.w, "%x\r\n%s\r\n", len(p), p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,156 @@
+package response
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"MyOwnHTTP/internal/headers"
+)
+
+var compressibleText = strings.Repeat("all work and no play makes jack a dull boy\n", 50)
+
+// writeCompressed runs write against a Writer set to compress with
+// encoding and parses what it wrote.
+func writeCompressed(t *testing.T, encoding string, write func(w *Writer)) (*http.Response, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.KeepAlive = true
+	w.Compression = &Compression{Encoding: encoding, MinSize: 100}
+	write(w)
+	assert.Equal(t, Done, w.WriterState)
+	resp, err := http.ReadResponse(bufio.NewReader(&buf), nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, body
+}
+
+func decompress(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader
+	var err error
+	if encoding == "deflate" {
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	} else {
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	}
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func writeWhole(contentType string, body []byte, extra ...string) func(w *Writer) {
+	return func(w *Writer) {
+		h := GetDefaultHeaders(len(body))
+		h.Set("Content-Type", contentType)
+		for i := 0; i < len(extra); i += 2 {
+			h.Set(extra[i], extra[i+1])
+		}
+		w.WriteStatusLine(StatusOK)
+		w.WriteHeaders(h)
+		w.WriteBody(body)
+	}
+}
+
+func TestCompressFixedLength(t *testing.T) {
+	for _, encoding := range Encodings {
+		t.Run(encoding, func(t *testing.T) {
+			// Test: Body compressed with its length rewritten
+			resp, body := writeCompressed(t, encoding, writeWhole("text/plain", []byte(compressibleText), "ETag", `"v1"`))
+			assert.Equal(t, encoding, resp.Header.Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+			assert.Equal(t, strconv.Itoa(len(body)), resp.Header.Get("Content-Length"))
+			assert.Less(t, len(body), len(compressibleText))
+			assert.Equal(t, compressibleText, decompress(t, encoding, body))
+			assert.False(t, resp.Close)
+			// Test: Strong ETag turns weak
+			assert.Equal(t, `W/"v1"`, resp.Header.Get("ETag"))
+		})
+	}
+
+	random := make([]byte, 500)
+	rand.Read(random)
+	cases := []struct {
+		name     string
+		write    func(w *Writer)
+		encoding string
+		vary     string
+	}{
+		{"below the threshold", writeWhole("text/plain", []byte("short")), "", "Accept-Encoding"},
+		{"already compressed type", writeWhole("video/mp4", []byte(compressibleText)), "", ""},
+		{"already encoded", writeWhole("text/plain", []byte(compressibleText), "Content-Encoding", "br"), "br", ""},
+		{"no gain", writeWhole("application/octet-stream+json", random), "", "Accept-Encoding"},
+		{"existing vary", writeWhole("text/plain", []byte("short"), "Vary", "Accept"), "", "Accept, Accept-Encoding"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Test: Sent as it is
+			resp, _ := writeCompressed(t, "gzip", c.write)
+			assert.Equal(t, c.encoding, resp.Header.Get("Content-Encoding"))
+			assert.Equal(t, c.vary, resp.Header.Get("Vary"))
+		})
+	}
+
+	// Test: No encoding accepted still varies
+	resp, body := writeCompressed(t, "", writeWhole("text/html", []byte(compressibleText)))
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+	assert.Equal(t, compressibleText, string(body))
+
+	// Test: Partial content is left alone
+	resp, _ = writeCompressed(t, "gzip", func(w *Writer) {
+		h := GetDefaultHeaders(len(compressibleText))
+		h.Set("Content-Range", "bytes 0-2199/5000")
+		w.WriteStatusLine(StatusPartialContent)
+		w.WriteHeaders(h)
+		w.WriteBody([]byte(compressibleText))
+	})
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+}
+
+func TestCompressChunked(t *testing.T) {
+	// Test: Each chunk is compressed and flushed, trailers still follow
+	resp, body := writeCompressed(t, "deflate", func(w *Writer) {
+		h := GetDefaultHeaders(0)
+		h.Del("Content-Length")
+		h.Set("Transfer-Encoding", "chunked")
+		h.Set("Trailer", "X-Done")
+		w.WriteStatusLine(StatusOK)
+		w.WriteHeaders(h)
+		w.WriteChunkedBody([]byte(compressibleText))
+		w.WriteChunkedBody([]byte("tail"))
+		trailers := headers.NewHeaders()
+		trailers.Set("X-Done", "yes")
+		w.WriteChunkedBodyDone(trailers)
+	})
+	assert.Equal(t, "deflate", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, compressibleText+"tail", decompress(t, "deflate", body))
+	assert.Equal(t, "yes", resp.Trailer.Get("X-Done"))
+
+	// Test: A streamed body switches to chunked
+	resp, body = writeCompressed(t, "gzip", func(w *Writer) {
+		h := GetDefaultHeaders(len(compressibleText))
+		h.Set("Content-Type", "application/json")
+		w.WriteStatusLine(StatusOK)
+		w.WriteHeaders(h)
+		w.WriteBodyFrom(strings.NewReader(compressibleText))
+	})
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, []string{"chunked"}, resp.TransferEncoding)
+	assert.Equal(t, int64(-1), resp.ContentLength)
+	assert.Equal(t, compressibleText, decompress(t, "gzip", body))
+	assert.False(t, resp.Close)
+}
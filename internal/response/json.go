@@ -0,0 +1,56 @@
+package response
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"MyOwnHTTP/internal/headers"
+)
+
+// WriteJSON writes a whole response with v encoded as its body. h may
+// be nil; either way Content-Length is set for the body and Content-Type
+// is application/json unless h names another type. v is encoded before
+// anything is written, so an error leaves the writer untouched.
+func (w *Writer) WriteJSON(statusCode StatusCode, h *headers.Headers, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	body = append(body, '\n')
+	if h == nil {
+		h = headers.NewHeaders()
+	}
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	if !h.Has("Content-Type") {
+		h.Set("Content-Type", "application/json")
+	}
+	if err := w.WriteStatusLine(statusCode); err != nil {
+		return err
+	}
+	if err := w.WriteHeaders(h); err != nil {
+		return err
+	}
+	_, err = w.WriteBody(body)
+	return err
+}
+
+// Problem is an RFC 9457 problem details object.
+type Problem struct {
+	// Type is a URI naming the kind of problem, about:blank if empty.
+	Type     string     `json:"type,omitempty"`
+	Title    string     `json:"title"`
+	Status   StatusCode `json:"status"`
+	Detail   string     `json:"detail,omitempty"`
+	Instance string     `json:"instance,omitempty"`
+}
+
+// WriteProblem answers with p as application/problem+json. An empty
+// Title is filled in with the reason phrase of p.Status.
+func (w *Writer) WriteProblem(p Problem) error {
+	if p.Title == "" {
+		p.Title = StatusText(p.Status)
+	}
+	h := headers.NewHeaders()
+	h.Set("Content-Type", "application/problem+json")
+	return w.WriteJSON(p.Status, h, p)
+}
@@ -0,0 +1,53 @@
+package response
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"MyOwnHTTP/internal/headers"
+)
+
+func TestWriteJSON(t *testing.T) {
+	// Test: Length and type are filled in
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.WriteJSON(StatusCreated, nil, map[string]int{"id": 7}))
+	assert.Equal(t, "HTTP/1.1 201 Created\r\n"+
+		"Content-Length: 9\r\n"+
+		"Content-Type: application/json\r\n"+
+		"Connection: close\r\n"+
+		"\r\n"+
+		"{\"id\":7}\n", buf.String())
+	assert.Equal(t, Done, w.WriterState)
+
+	// Test: The caller's headers and content type are kept
+	buf.Reset()
+	w = NewWriter(&buf)
+	h := headers.NewHeaders()
+	h.Set("Content-Type", "application/vnd.api+json")
+	h.Set("Location", "/items/7")
+	require.NoError(t, w.WriteJSON(StatusOK, h, []string{}))
+	assert.Contains(t, buf.String(), "Content-Type: application/vnd.api+json\r\nLocation: /items/7\r\nContent-Length: 3\r\n")
+
+	// Test: A value that can't be encoded writes nothing
+	buf.Reset()
+	w = NewWriter(&buf)
+	assert.Error(t, w.WriteJSON(StatusOK, nil, func() {}))
+	assert.Empty(t, buf.String())
+	assert.Equal(t, ReadyForStatusLine, w.WriterState)
+}
+
+func TestWriteProblem(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.WriteProblem(Problem{Status: StatusBadRequest, Detail: "missing name"}))
+	assert.Equal(t, "HTTP/1.1 400 Bad Request\r\n"+
+		"Content-Type: application/problem+json\r\n"+
+		"Content-Length: 61\r\n"+
+		"Connection: close\r\n"+
+		"\r\n"+
+		`{"title":"Bad Request","status":400,"detail":"missing name"}`+"\n", buf.String())
+}
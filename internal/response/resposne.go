@@ -1,23 +1,16 @@
 package response
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log"
-	"net"
 	"strconv"
+	"strings"
 
 	"MyOwnHTTP/internal/headers"
 )
 
-type StatusCode int
-
-const (
-	StatusOK                  StatusCode = 200
-	StatusBadRequest          StatusCode = 400
-	StatusInternalServerError StatusCode = 500
-)
-
 type WriterState int
 
 const (
@@ -30,39 +23,46 @@ const (
 type Writer struct {
 	Buffer      io.Writer
 	WriterState WriterState
+	// KeepAlive reports whether the connection can be reused once the
+	// response is done. The server sets it before calling the handler and
+	// WriteHeaders clears it when the response has no length framing.
+	KeepAlive bool
+	// StatusCode is the status written by WriteStatusLine, or 0 before it.
+	StatusCode StatusCode
+	// ExtraHeaders are added to the headers passed to WriteHeaders unless
+	// the handler already set them. Middleware uses it to tag responses.
+	ExtraHeaders *headers.Headers
+	// Compression, when set, compresses the bodies worth compressing.
+	Compression *Compression
+	// OmitBody sends the status line and headers only, whatever body the
+	// handler writes. The server sets it for HEAD requests, whose
+	// responses must not have a body.
+	OmitBody bool
+	// pending holds headers held back until the body shows whether it
+	// gets compressed
+	pending *headers.Headers
+	// encoder compresses a streamed body into chunks
+	encoder compressor
 }
 
-func NewWriter(conn net.Conn) *Writer {
+func NewWriter(w io.Writer) *Writer {
 	return &Writer{
-		Buffer:      conn,
+		Buffer:      w,
 		WriterState: ReadyForStatusLine,
 	}
 }
 
 func GetDefaultStatusLine(w io.Writer, statusCode StatusCode) error {
-	var err error
-	switch statusCode {
-	case StatusOK:
-		_, err = w.Write([]byte("HTTP/1.1 200 OK\r\n"))
-	case StatusBadRequest:
-		_, err = w.Write([]byte("HTTP/1.1 400 Bad Request\r\n"))
-	case StatusInternalServerError:
-		_, err = w.Write([]byte("HTTP/1.1 500 Internal Server Error\r\n"))
-	default:
-		_, err = w.Write([]byte("HTTP/1.1 200"))
-		log.Printf("Invalid status code recieved: %d\n", statusCode)
+	if StatusText(statusCode) == "" {
+		log.Printf("Unregistered status code received: %d\n", statusCode)
 	}
-	if err != nil {
-		log.Printf("Failed to write status code: %v\n", err)
-	}
-	return nil
+	return writeStatusLine(w, statusCode, StatusText(statusCode))
 }
 
-func GetDefaultHeaders(contentLen int) headers.Headers {
+func GetDefaultHeaders(contentLen int) *headers.Headers {
 	currHeaders := headers.NewHeaders()
-	currHeaders["Content-length"] = strconv.Itoa(contentLen)
-	currHeaders["Connection"] = "close"
-	currHeaders["Content-Type"] = "text/html"
+	currHeaders.Set("Content-Length", strconv.Itoa(contentLen))
+	currHeaders.Set("Content-Type", "text/html")
 
 	return currHeaders
 }
@@ -73,44 +73,116 @@ func (w *Writer) WriteStatusLine(statusCode StatusCode) error {
 	}
 	err := GetDefaultStatusLine(w.Buffer, statusCode)
 	if err != nil {
-		return nil
+		return err
 	}
+	w.StatusCode = statusCode
 	w.WriterState = ReadyForHeader
 	return nil
 }
 
-func (w *Writer) WriteHeaders(headers headers.Headers) error {
+// WriteStatusLineWithReason writes any three-digit status code with a
+// reason phrase of the caller's choosing.
+func (w *Writer) WriteStatusLineWithReason(statusCode StatusCode, reason string) error {
+	if w.WriterState != ReadyForStatusLine {
+		return fmt.Errorf("incorrect order of operations expected: %v, Got: %v", ReadyForStatusLine, w.WriterState)
+	}
+	err := writeStatusLine(w.Buffer, statusCode, reason)
+	if err != nil {
+		return err
+	}
+	w.StatusCode = statusCode
+	w.WriterState = ReadyForHeader
+	return nil
+}
+
+func (w *Writer) WriteHeaders(headers *headers.Headers) error {
 	if w.WriterState != ReadyForHeader {
 		return fmt.Errorf("incorrect order of operations expected: %v, Got: %v", ReadyForHeader, w.WriterState)
 	}
-	message := ""
-	for key, value := range headers {
-		message = fmt.Sprintf("%s%s:%s\r\n", message, key, value)
+	missing := map[string]bool{}
+	for key := range w.ExtraHeaders.All() {
+		missing[key] = !headers.Has(key)
 	}
-	message += "\r\n"
-	_, err := w.Buffer.Write([]byte(message))
-	if err != nil {
+	for key, value := range w.ExtraHeaders.All() {
+		if missing[key] {
+			headers.Add(key, value)
+		}
+	}
+	if !w.OmitBody && w.startCompression(headers) {
+		// sent along with the body, once we know if compressing it pays
+		w.pending = headers
+		w.WriterState = ReadyForBody
+		return nil
+	}
+	if err := w.writeHeaderSection(headers); err != nil {
 		return err
 	}
 	w.WriterState = ReadyForBody
 	return nil
 }
 
+// writeHeaderSection settles the Connection header and writes the header
+// section.
+func (w *Writer) writeHeaderSection(headers *headers.Headers) error {
+	if !hasFraming(headers) && !bodyless(w.StatusCode) {
+		// without a length the client can only find the end of the body
+		// by us closing the connection
+		w.KeepAlive = false
+	}
+	if w.KeepAlive {
+		headers.Set("Connection", "keep-alive")
+	} else {
+		headers.Set("Connection", "close")
+	}
+	return writeFields(w.Buffer, headers)
+}
+
 func (w *Writer) WriteBody(p []byte) (int, error) {
 	if w.WriterState != ReadyForBody {
 		return 0, fmt.Errorf("incorrect order of operations expected: %v, Got: %v", ReadyForBody, w.WriterState)
 	}
 	w.WriterState = Done
+	if w.OmitBody {
+		return len(p), nil
+	}
+	if w.pending != nil {
+		return w.writeCompressedBody(p)
+	}
 	return w.Buffer.Write(p)
 }
 
+// WriteBodyFrom copies the body from r, so a large body like a file
+// doesn't have to be held in memory.
+func (w *Writer) WriteBodyFrom(r io.Reader) (int64, error) {
+	if w.WriterState != ReadyForBody {
+		return 0, fmt.Errorf("incorrect order of operations expected: %v, Got: %v", ReadyForBody, w.WriterState)
+	}
+	w.WriterState = Done
+	if w.OmitBody {
+		return 0, nil
+	}
+	if w.pending != nil {
+		return w.streamCompressedBody(r)
+	}
+	return io.Copy(w.Buffer, r)
+}
+
 func (w *Writer) WriteChunkedBody(p []byte) (int, error) {
 	if w.WriterState != ReadyForBody {
 		return 0, fmt.Errorf("incorrect order of operations expected: %v, Got: %v", ReadyForBody, w.WriterState)
 	}
+	if w.OmitBody {
+		return len(p), nil
+	}
+	if w.encoder != nil {
+		// the encoder writes the compressed chunks itself
+		if _, err := w.encoder.Write(p); err != nil {
+			return 0, err
+		}
+		return len(p), w.encoder.Flush()
+	}
 	hexLength := fmt.Sprintf("%x", len(p))
 	responseBody := fmt.Sprintf("%s\r\n%s\r\n", hexLength, string(p))
-	fmt.Println("The response body is: ", responseBody)
 	n, err := w.Buffer.Write([]byte(responseBody))
 	if err != nil {
 		return 0, err
@@ -119,14 +191,22 @@ func (w *Writer) WriteChunkedBody(p []byte) (int, error) {
 	return n, nil
 }
 
-func (w *Writer) WriteChunkedBodyDone(trailers headers.Headers) (int, error) {
+func (w *Writer) WriteChunkedBodyDone(trailers *headers.Headers) (int, error) {
+	if w.OmitBody {
+		w.WriterState = Done
+		return 0, nil
+	}
+	if w.encoder != nil {
+		if err := w.encoder.Close(); err != nil {
+			return 0, err
+		}
+	}
 	message := []byte("0\r\n")
 	_, err := w.Buffer.Write(message)
 	if err != nil {
 		return 0, err
 	}
 	err = w.WriteTrailers(trailers)
-	_, err = w.Buffer.Write([]byte("\r\n"))
 	if err != nil {
 		return 0, err
 	}
@@ -134,16 +214,34 @@ func (w *Writer) WriteChunkedBodyDone(trailers headers.Headers) (int, error) {
 	return 0, nil
 }
 
-func (w *Writer) WriteTrailers(h headers.Headers) error {
-	message := ""
-	for key, value := range h {
-		message = fmt.Sprintf("%s%s:%s\r\n", message, key, value)
-	}
-	message += "\r\n"
-	_, err := w.Buffer.Write([]byte(message))
-	if err != nil {
+func (w *Writer) WriteTrailers(h *headers.Headers) error {
+	if err := writeFields(w.Buffer, h); err != nil {
 		return err
 	}
 	w.WriterState = ReadyForBody
 	return nil
 }
+
+// writeFields writes a header or trailer section along with the blank
+// line that ends it, in one write.
+func writeFields(w io.Writer, h *headers.Headers) error {
+	var buf bytes.Buffer
+	h.WriteTo(&buf)
+	buf.WriteString("\r\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// bodyless reports the statuses that never have a body, so need no
+// framing either.
+func bodyless(statusCode StatusCode) bool {
+	return statusCode < 200 || statusCode == StatusNoContent || statusCode == StatusNotModified
+}
+
+func hasFraming(h *headers.Headers) bool {
+	if _, err := h.Get("Content-Length"); err == nil {
+		return true
+	}
+	te, err := h.Get("Transfer-Encoding")
+	return err == nil && strings.Contains(strings.ToLower(te), "chunked")
+}
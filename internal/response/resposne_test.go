@@ -0,0 +1,98 @@
+package response
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"MyOwnHTTP/internal/headers"
+)
+
+func TestWriteHeaders(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.KeepAlive = true
+	w.ExtraHeaders = headers.NewHeaders()
+	w.ExtraHeaders.Add("X-Request-Id", "abc")
+	w.ExtraHeaders.Add("Content-Type", "ignored/extra")
+
+	h := GetDefaultHeaders(2)
+	h.Set("content-type", "text/plain")
+	h.Add("set-cookie", "a=1")
+	h.Add("set-cookie", "b=2")
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(h))
+	_, err := w.WriteBody([]byte("ok"))
+	require.NoError(t, err)
+
+	// Test: Same order every time, canonical names, repeated fields and
+	// handler values winning over extra ones
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Length: 2\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"Set-Cookie: a=1\r\n"+
+		"Set-Cookie: b=2\r\n"+
+		"X-Request-Id: abc\r\n"+
+		"Connection: keep-alive\r\n"+
+		"\r\n"+
+		"ok", buf.String())
+}
+
+func TestWriteTrailers(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	h := GetDefaultHeaders(0)
+	h.Del("Content-Length")
+	h.Set("Transfer-Encoding", "chunked")
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(h))
+	buf.Reset()
+
+	trailers := headers.NewHeaders()
+	trailers.Set("x-checksum", "abc")
+	_, err := w.WriteChunkedBodyDone(trailers)
+	require.NoError(t, err)
+	assert.Equal(t, "0\r\nX-Checksum: abc\r\n\r\n", buf.String())
+	assert.Equal(t, Done, w.WriterState)
+}
+
+func TestOmitBody(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.KeepAlive = true
+	w.OmitBody = true
+
+	// Test: Headers go out, the body doesn't
+	require.NoError(t, w.WriteStatusLine(StatusNotFound))
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(10)))
+	n, err := w.WriteBody([]byte("Not Found\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 10, n)
+	assert.Equal(t, Done, w.WriterState)
+	assert.Equal(t, "HTTP/1.1 404 Not Found\r\n"+
+		"Content-Length: 10\r\n"+
+		"Content-Type: text/html\r\n"+
+		"Connection: keep-alive\r\n"+
+		"\r\n", buf.String())
+
+	// Test: Nor does a chunked body or its trailers
+	buf.Reset()
+	w = NewWriter(&buf)
+	w.OmitBody = true
+	h := GetDefaultHeaders(0)
+	h.Del("Content-Length")
+	h.Set("Transfer-Encoding", "chunked")
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(h))
+	headerLen := buf.Len()
+	_, err = w.WriteChunkedBody([]byte("data"))
+	require.NoError(t, err)
+	trailers := headers.NewHeaders()
+	trailers.Set("X-Checksum", "abc")
+	_, err = w.WriteChunkedBodyDone(trailers)
+	require.NoError(t, err)
+	assert.Equal(t, headerLen, buf.Len())
+	assert.Equal(t, Done, w.WriterState)
+}
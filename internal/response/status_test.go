@@ -0,0 +1,46 @@
+package response
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusLine(t *testing.T) {
+	// Test: Registered code gets its canonical reason
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	require.NoError(t, w.WriteStatusLine(StatusNotFound))
+	assert.Equal(t, "HTTP/1.1 404 Not Found\r\n", buf.String())
+	assert.Equal(t, ReadyForHeader, w.WriterState)
+
+	// Test: Unregistered code still gets a well formed line
+	buf.Reset()
+	w = NewWriter(&buf)
+	require.NoError(t, w.WriteStatusLine(StatusCode(299)))
+	assert.Equal(t, "HTTP/1.1 299 \r\n", buf.String())
+
+	// Test: Custom reason phrase
+	buf.Reset()
+	w = NewWriter(&buf)
+	require.NoError(t, w.WriteStatusLineWithReason(StatusCode(599), "Network Read Timeout"))
+	assert.Equal(t, "HTTP/1.1 599 Network Read Timeout\r\n", buf.String())
+
+	// Test: Code that isn't three digits
+	buf.Reset()
+	w = NewWriter(&buf)
+	require.Error(t, w.WriteStatusLine(StatusCode(42)))
+	assert.Empty(t, buf.String())
+	assert.Equal(t, ReadyForStatusLine, w.WriterState)
+
+	// Test: Reason phrase can't inject a header
+	w = NewWriter(&buf)
+	require.Error(t, w.WriteStatusLineWithReason(StatusOK, "OK\r\nSet-Cookie: x=y"))
+	assert.Empty(t, buf.String())
+
+	// Test: Lookup
+	assert.Equal(t, "", StatusText(418))
+	assert.Equal(t, "Too Many Requests", StatusText(StatusTooManyRequests))
+}
@@ -0,0 +1,46 @@
+package router
+
+import (
+	"strings"
+
+	"MyOwnHTTP/internal/server"
+)
+
+// Group registers routes on its router under a shared prefix.
+type Group struct {
+	router *Router
+	prefix string
+}
+
+func (g *Group) Handle(method, pattern string, handler server.Handler) {
+	if pattern == "/" && g.prefix != "" {
+		// the group root is the prefix itself, not prefix + "/"
+		pattern = ""
+	}
+	g.router.Handle(method, g.prefix+pattern, handler)
+}
+
+func (g *Group) Get(pattern string, handler server.Handler) {
+	g.Handle("GET", pattern, handler)
+}
+
+func (g *Group) Post(pattern string, handler server.Handler) {
+	g.Handle("POST", pattern, handler)
+}
+
+func (g *Group) Put(pattern string, handler server.Handler) {
+	g.Handle("PUT", pattern, handler)
+}
+
+func (g *Group) Patch(pattern string, handler server.Handler) {
+	g.Handle("PATCH", pattern, handler)
+}
+
+func (g *Group) Delete(pattern string, handler server.Handler) {
+	g.Handle("DELETE", pattern, handler)
+}
+
+// Group nests another prefix under this group's.
+func (g *Group) Group(prefix string) *Group {
+	return &Group{router: g.router, prefix: g.prefix + strings.TrimRight(prefix, "/")}
+}
@@ -0,0 +1,233 @@
+package router
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"MyOwnHTTP/internal/headers"
+	"MyOwnHTTP/internal/request"
+	"MyOwnHTTP/internal/response"
+	"MyOwnHTTP/internal/server"
+)
+
+// wildcard as the last segment of a pattern matches the rest of the path.
+// What it matched is available as the "*" param.
+const wildcard = "*"
+
+type Router struct {
+	routes []*route
+	// NotFound runs when no route matches the path. Defaults to a plain
+	// 404 response.
+	NotFound server.Handler
+}
+
+type route struct {
+	method   string
+	segments []segment
+	handler  server.Handler
+}
+
+type segment struct {
+	value   string
+	isParam bool
+}
+
+func New() *Router {
+	return &Router{}
+}
+
+// Handler returns the router as a server.Handler.
+func (r *Router) Handler() server.Handler {
+	return r.serve
+}
+
+// Handle registers a handler for a method and path pattern. Segments like
+// {id} match any single segment and a trailing * matches the rest of the
+// path. It panics on malformed patterns since those are programming
+// errors.
+func (r *Router) Handle(method, pattern string, handler server.Handler) {
+	segments, err := parsePattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+	r.routes = append(r.routes, &route{
+		method:   method,
+		segments: segments,
+		handler:  handler,
+	})
+}
+
+func (r *Router) Get(pattern string, handler server.Handler) {
+	r.Handle("GET", pattern, handler)
+}
+
+func (r *Router) Post(pattern string, handler server.Handler) {
+	r.Handle("POST", pattern, handler)
+}
+
+func (r *Router) Put(pattern string, handler server.Handler) {
+	r.Handle("PUT", pattern, handler)
+}
+
+func (r *Router) Patch(pattern string, handler server.Handler) {
+	r.Handle("PATCH", pattern, handler)
+}
+
+func (r *Router) Delete(pattern string, handler server.Handler) {
+	r.Handle("DELETE", pattern, handler)
+}
+
+// Group returns a group whose routes all live under prefix.
+func (r *Router) Group(prefix string) *Group {
+	return &Group{router: r, prefix: strings.TrimRight(prefix, "/")}
+}
+
+func (r *Router) serve(w *response.Writer, req *request.Request) {
+	path := requestPath(req)
+	var best *route
+	var bestParams map[string]string
+	var allowed []string
+	for _, rt := range r.routes {
+		params, ok := rt.match(path)
+		if !ok {
+			continue
+		}
+		if !slices.Contains(allowed, rt.method) {
+			allowed = append(allowed, rt.method)
+		}
+		if !rt.handles(req.RequestLine.Method) {
+			continue
+		}
+		if best == nil || rt.moreSpecificThan(best) ||
+			(!best.moreSpecificThan(rt) && rt.method == req.RequestLine.Method) {
+			best = rt
+			bestParams = params
+		}
+	}
+
+	switch {
+	case best != nil:
+		req.Params = bestParams
+		best.handler(w, req)
+	case len(allowed) > 0:
+		if slices.Contains(allowed, "GET") && !slices.Contains(allowed, "HEAD") {
+			allowed = append(allowed, "HEAD")
+		}
+		slices.Sort(allowed)
+		h := headers.NewHeaders()
+		h.Set("Allow", strings.Join(allowed, ", "))
+		writeStatus(w, response.StatusMethodNotAllowed, h)
+	case r.NotFound != nil:
+		r.NotFound(w, req)
+	default:
+		writeStatus(w, response.StatusNotFound, headers.NewHeaders())
+	}
+}
+
+// requestPath is the decoded, normalized path routes are matched on.
+func requestPath(req *request.Request) string {
+	return req.URL.Path
+}
+
+// writeStatus answers with the reason phrase as a plain text body, on
+// top of any extra headers.
+func writeStatus(w *response.Writer, statusCode response.StatusCode, extra *headers.Headers) {
+	body := []byte(response.StatusText(statusCode) + "\n")
+	h := response.GetDefaultHeaders(len(body))
+	h.Set("Content-Type", "text/plain")
+	for key, value := range extra.All() {
+		h.Set(key, value)
+	}
+	w.WriteStatusLine(statusCode)
+	w.WriteHeaders(h)
+	w.WriteBody(body)
+}
+
+func parsePattern(pattern string) ([]segment, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("router: pattern must start with '/': %q", pattern)
+	}
+	parts := strings.Split(pattern[1:], "/")
+	segments := make([]segment, 0, len(parts))
+	for i, part := range parts {
+		switch {
+		case part == wildcard:
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("router: %q must be the last segment: %q", wildcard, pattern)
+			}
+			segments = append(segments, segment{value: wildcard, isParam: true})
+		case strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}"):
+			name := part[1 : len(part)-1]
+			if name == "" || name == wildcard || strings.ContainsAny(name, "{}") {
+				return nil, fmt.Errorf("router: bad parameter %q in %q", part, pattern)
+			}
+			segments = append(segments, segment{value: name, isParam: true})
+		case strings.ContainsAny(part, "{}"):
+			return nil, fmt.Errorf("router: bad segment %q in %q", part, pattern)
+		default:
+			segments = append(segments, segment{value: part})
+		}
+	}
+	return segments, nil
+}
+
+func (rt *route) match(path string) (map[string]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	parts := strings.Split(path[1:], "/")
+	params := map[string]string{}
+	for i, seg := range rt.segments {
+		if seg.value == wildcard && seg.isParam {
+			params[wildcard] = strings.Join(parts[i:], "/")
+			return params, true
+		}
+		if i >= len(parts) {
+			return nil, false
+		}
+		if seg.isParam {
+			if parts[i] == "" {
+				return nil, false
+			}
+			params[seg.value] = parts[i]
+		} else if seg.value != parts[i] {
+			return nil, false
+		}
+	}
+	if len(parts) != len(rt.segments) {
+		return nil, false
+	}
+	return params, true
+}
+
+// handles reports whether the route serves method. GET routes serve HEAD
+// too, with the server leaving the body out, though a HEAD route of its
+// own wins over them.
+func (rt *route) handles(method string) bool {
+	return rt.method == method || (method == "HEAD" && rt.method == "GET")
+}
+
+// moreSpecificThan prefers routes whose earliest differing segment is
+// static over a parameter, and parameters over a wildcard, so /users/me
+// wins over /users/{id}.
+func (rt *route) moreSpecificThan(other *route) bool {
+	for i := 0; i < len(rt.segments) && i < len(other.segments); i++ {
+		a, b := rt.segments[i].rank(), other.segments[i].rank()
+		if a != b {
+			return a > b
+		}
+	}
+	return len(rt.segments) > len(other.segments)
+}
+
+func (s segment) rank() int {
+	switch {
+	case !s.isParam:
+		return 2
+	case s.value != wildcard:
+		return 1
+	default:
+		return 0
+	}
+}
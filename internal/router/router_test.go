@@ -0,0 +1,112 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"MyOwnHTTP/internal/request"
+	"MyOwnHTTP/internal/response"
+)
+
+// reply writes name followed by the matched params so tests can see which
+// route ran.
+func reply(name string) func(w *response.Writer, req *request.Request) {
+	return func(w *response.Writer, req *request.Request) {
+		body := name
+		for _, key := range []string{"id", "post", "*"} {
+			if value, ok := req.Params[key]; ok {
+				body += " " + key + "=" + value
+			}
+		}
+		w.WriteStatusLine(response.StatusOK)
+		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		w.WriteBody([]byte(body))
+	}
+}
+
+func do(t *testing.T, r *Router, method, target string) (*http.Response, string) {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader(method + " " + target + " HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	r.Handler()(response.NewWriter(&buf), req)
+	resp, err := http.ReadResponse(bufio.NewReader(&buf), nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestRouter(t *testing.T) {
+	r := New()
+	r.Get("/", reply("root"))
+	r.Get("/users/{id}", reply("user"))
+	r.Get("/users/me", reply("me"))
+	r.Delete("/users/{id}", reply("delete"))
+	r.Get("/users/{id}/posts/{post}", reply("post"))
+	r.Get("/static/*", reply("static"))
+	api := r.Group("/api/v1")
+	api.Get("/", reply("api"))
+	api.Post("/items", reply("items"))
+	api.Group("/admin").Get("/stats", reply("stats"))
+	r.Handle("HEAD", "/users/me", reply("head me"))
+
+	cases := []struct {
+		method string
+		target string
+		status int
+		body   string
+	}{
+		{"GET", "/", 200, "root"},
+		{"GET", "/users/42", 200, "user id=42"},
+		{"GET", "/users/42?full=true", 200, "user id=42"},
+		{"GET", "/users/me", 200, "me"},
+		{"DELETE", "/users/42", 200, "delete id=42"},
+		{"GET", "/users/42/posts/7", 200, "post id=42 post=7"},
+		{"GET", "/static/css/site.css", 200, "static *=css/site.css"},
+		{"GET", "/api/v1", 200, "api"},
+		{"POST", "/api/v1/items", 200, "items"},
+		{"GET", "/api/v1/admin/stats", 200, "stats"},
+		{"GET", "http://localhost/users/42?full=true", 200, "user id=42"},
+		{"GET", "http://localhost", 200, "root"},
+		{"GET", "/users", 404, "Not Found\n"},
+		{"GET", "/users/", 404, "Not Found\n"},
+		{"GET", "/nope", 404, "Not Found\n"},
+		{"PUT", "/users/42", 405, "Method Not Allowed\n"},
+		{"HEAD", "/users/42", 200, "user id=42"},
+		{"HEAD", "/users/me", 200, "head me"},
+		{"HEAD", "/api/v1/items", 405, "Method Not Allowed\n"},
+	}
+	for _, c := range cases {
+		t.Run(c.method+" "+c.target, func(t *testing.T) {
+			resp, body := do(t, r, c.method, c.target)
+			assert.Equal(t, c.status, resp.StatusCode)
+			assert.Equal(t, c.body, body)
+		})
+	}
+
+	// Test: 405 lists the methods the path does support, HEAD along with
+	// GET
+	resp, _ := do(t, r, "PUT", "/users/42")
+	assert.Equal(t, "DELETE, GET, HEAD", resp.Header.Get("Allow"))
+	resp, _ = do(t, r, "GET", "/api/v1/items")
+	assert.Equal(t, "POST", resp.Header.Get("Allow"))
+
+	// Test: Custom not found handler
+	r.NotFound = reply("custom")
+	_, body := do(t, r, "GET", "/nope")
+	assert.Equal(t, "custom", body)
+}
+
+func TestBadPatterns(t *testing.T) {
+	for _, pattern := range []string{"users", "/files/*/more", "/users/{}", "/users/{id", "/a/{*}"} {
+		assert.Panics(t, func() { New().Get(pattern, reply("x")) }, pattern)
+	}
+}
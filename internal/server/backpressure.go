@@ -0,0 +1,98 @@
+package server
+
+import (
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"MyOwnHTTP/internal/headers"
+	"MyOwnHTTP/internal/response"
+)
+
+const (
+	minAcceptBackoff = 5 * time.Millisecond
+	maxAcceptBackoff = time.Second
+)
+
+// limiter caps how many of something run at once. A nil limiter lets
+// everything through.
+type limiter chan struct{}
+
+func newLimiter(n int) limiter {
+	if n <= 0 {
+		return nil
+	}
+	return make(limiter, n)
+}
+
+// acquire takes a place, waiting up to wait for one to free up. It
+// reports whether it got one.
+func (l limiter) acquire(wait time.Duration) bool {
+	if l == nil {
+		return true
+	}
+	select {
+	case l <- struct{}{}:
+		return true
+	default:
+	}
+	if wait <= 0 {
+		return false
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case l <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (l limiter) release() {
+	if l != nil {
+		<-l
+	}
+}
+
+// acceptBackoff spaces out retries after Accept fails, as it does when
+// we run out of file descriptors, so the loop doesn't spin on the error.
+type acceptBackoff struct {
+	delay time.Duration
+}
+
+func (b *acceptBackoff) wait() time.Duration {
+	if b.delay == 0 {
+		b.delay = minAcceptBackoff
+	} else {
+		b.delay = min(b.delay*2, maxAcceptBackoff)
+	}
+	time.Sleep(b.delay)
+	return b.delay
+}
+
+func (b *acceptBackoff) reset() {
+	b.delay = 0
+}
+
+// rejectConn answers a connection we have no room for with a 503 and
+// closes it, without reading the request.
+func (s *Server) rejectConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetWriteDeadline(deadline(s.options.WriteTimeout))
+	w := response.NewWriter(conn)
+	writeServiceUnavailable(w, s.options.RetryAfter)
+	log.Printf("Too many connections, turned away %v\n", conn.RemoteAddr())
+}
+
+// writeServiceUnavailable tells the client we are too busy and when to
+// try again.
+func writeServiceUnavailable(w *response.Writer, retryAfter time.Duration) {
+	if w.ExtraHeaders == nil {
+		w.ExtraHeaders = headers.NewHeaders()
+	}
+	seconds := int((retryAfter + time.Second - 1) / time.Second)
+	w.ExtraHeaders.Set("Retry-After", strconv.Itoa(seconds))
+	writeErrorResponse(w, response.StatusServiceUnavailable, response.StatusText(response.StatusServiceUnavailable))
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"MyOwnHTTP/internal/request"
+	"MyOwnHTTP/internal/response"
+)
+
+// get does one request on conn and returns the response.
+func get(t *testing.T, conn net.Conn, r *bufio.Reader) (*http.Response, string) {
+	t.Helper()
+	_, err := conn.Write([]byte("GET /ok HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	return readResponse(t, r)
+}
+
+func TestMaxConns(t *testing.T) {
+	s := startServer(t, echoTarget, Options{MaxConns: 1, RetryAfter: 1500 * time.Millisecond})
+	addr := s.Addr().String()
+
+	held, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	resp, _ := get(t, held, bufio.NewReader(held))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Test: Connections over the cap are turned away
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	resp, _ = readResponse(t, bufio.NewReader(conn))
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "2", resp.Header.Get("Retry-After"))
+	assert.True(t, resp.Close)
+
+	// Test: Room frees up once a connection closes
+	held.Close()
+	require.Eventually(t, func() bool { return s.ConnCount.Load() == 0 }, time.Second, 5*time.Millisecond)
+	conn, err = net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	resp, body := get(t, conn, bufio.NewReader(conn))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "/ok", body)
+}
+
+func TestMaxConnsWait(t *testing.T) {
+	s := startServer(t, echoTarget, Options{MaxConns: 1, LimitWait: time.Second})
+	addr := s.Addr().String()
+
+	held, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	get(t, held, bufio.NewReader(held))
+
+	// Test: Connections over the cap wait for room instead
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	time.AfterFunc(50*time.Millisecond, func() { held.Close() })
+	resp, _ := get(t, conn, bufio.NewReader(conn))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	slow := func(w *response.Writer, req *request.Request) {
+		if req.RequestLine.RequestTarget == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+		echoTarget(w, req)
+	}
+
+	tests := []struct {
+		name      string
+		limitWait time.Duration
+		want      int
+	}{
+		// Test: Requests over the cap get a 503
+		{"reject", 0, http.StatusServiceUnavailable},
+		// Test: Requests over the cap wait for a running one to finish
+		{"wait", time.Second, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := startServer(t, slow, Options{MaxConcurrentRequests: 1, LimitWait: tt.limitWait})
+			addr := s.Addr().String()
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				conn, err := net.Dial("tcp", addr)
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer conn.Close()
+				conn.Write([]byte("GET /slow HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+				resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+				if assert.NoError(t, err) {
+					assert.Equal(t, http.StatusOK, resp.StatusCode)
+				}
+			}()
+			<-started
+
+			conn, err := net.Dial("tcp", addr)
+			require.NoError(t, err)
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			time.AfterFunc(50*time.Millisecond, func() { release <- struct{}{} })
+			resp, _ := get(t, conn, r)
+			assert.Equal(t, tt.want, resp.StatusCode)
+			if tt.want == http.StatusServiceUnavailable {
+				assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+				// Test: The connection stays usable after a 503
+				time.Sleep(100 * time.Millisecond)
+				resp, _ = get(t, conn, r)
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+// failingListener fails Accept a few times, recording when, and then
+// reports itself closed.
+type failingListener struct {
+	net.Listener
+	failures int
+	calls    []time.Time
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	l.calls = append(l.calls, time.Now())
+	if len(l.calls) <= l.failures {
+		return nil, errors.New("too many open files")
+	}
+	return nil, net.ErrClosed
+}
+
+func TestAcceptBackoff(t *testing.T) {
+	ln := &failingListener{failures: 4}
+	s := &Server{Listener: ln}
+	s.listen()
+
+	// Test: Each retry waits longer than the one before
+	require.Len(t, ln.calls, 5)
+	for i := 1; i < len(ln.calls); i++ {
+		gap := ln.calls[i].Sub(ln.calls[i-1])
+		assert.GreaterOrEqual(t, gap, minAcceptBackoff<<(i-1))
+	}
+}
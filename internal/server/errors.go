@@ -0,0 +1,52 @@
+package server
+
+import (
+	"errors"
+
+	"MyOwnHTTP/internal/request"
+	"MyOwnHTTP/internal/response"
+)
+
+// requestErrorStatus maps an error from reading a request to the status
+// we answer it with. Errors that aren't about the request itself, like a
+// reset connection, get no response.
+func requestErrorStatus(err error) (response.StatusCode, bool) {
+	switch {
+	case errors.Is(err, request.ErrUnsupportedVersion):
+		return response.StatusHTTPVersionNotSupported, true
+	case errors.Is(err, request.ErrMalformedRequestLine),
+		errors.Is(err, request.ErrMalformedHeader),
+		errors.Is(err, request.ErrBodyLengthMismatch),
+		errors.Is(err, request.ErrAmbiguousFraming),
+		errors.Is(err, request.ErrInvalidHost),
+		errors.Is(err, request.ErrInvalidTarget),
+		errors.Is(err, request.ErrIncompleteRequest):
+		return response.StatusBadRequest, true
+	case errors.Is(err, request.ErrRequestLineTooLong):
+		return response.StatusURITooLong, true
+	case errors.Is(err, request.ErrHeadersTooLarge):
+		return response.StatusRequestHeaderFieldsTooLarge, true
+	case errors.Is(err, request.ErrBodyTooLarge):
+		return response.StatusContentTooLarge, true
+	}
+	return 0, false
+}
+
+// rejectRequest queues an error response behind any pipelined responses
+// still in flight. The connection is closed after it goes out since we
+// can't tell where the bad request ends.
+func rejectRequest(queue chan<- *pipelineSlot, statusCode response.StatusCode, message string) {
+	slot := newPipelineSlot("", false)
+	queue <- slot
+	defer slot.finish()
+	writeErrorResponse(slot.writer, statusCode, message)
+}
+
+func writeErrorResponse(w *response.Writer, statusCode response.StatusCode, message string) {
+	body := []byte(message + "\n")
+	h := response.GetDefaultHeaders(len(body))
+	h.Set("Content-Type", "text/plain")
+	w.WriteStatusLine(statusCode)
+	w.WriteHeaders(h)
+	w.WriteBody(body)
+}
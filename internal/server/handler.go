@@ -1,6 +1,12 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+
 	"MyOwnHTTP/internal/request"
 	"MyOwnHTTP/internal/response"
 )
@@ -8,6 +14,94 @@ import (
 type HandlerError struct {
 	StatusCode response.StatusCode
 	Message    string
+	// Problem answers with application/problem+json whatever the Accept
+	// header asks for, which JSON endpoints want for every error.
+	Problem bool
 }
 
 type Handler func(w *response.Writer, req *request.Request)
+
+// ErrorHandler is a handler that can give up by returning an error
+// instead of writing the error response itself.
+type ErrorHandler func(w *response.Writer, req *request.Request) *HandlerError
+
+func (e *HandlerError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, response.StatusText(e.StatusCode), e.Message)
+}
+
+// JSONError turns an error from Request.DecodeJSON into a problem
+// response: 415 for a body that isn't JSON, 413 for one that is too
+// large, before or after decompressing, and 400 for anything malformed.
+func JSONError(err error) *HandlerError {
+	statusCode := response.StatusBadRequest
+	switch {
+	case errors.Is(err, request.ErrNotJSON):
+		statusCode = response.StatusUnsupportedMediaType
+	case errors.Is(err, request.ErrJSONTooLarge), errors.Is(err, request.ErrDecodedBodyTooLarge):
+		statusCode = response.StatusContentTooLarge
+	}
+	return &HandlerError{StatusCode: statusCode, Message: err.Error(), Problem: true}
+}
+
+// HandleErrors adapts an ErrorHandler to a Handler. A returned error is
+// rendered as HTML, JSON, problem+json or plain text depending on the
+// request's Accept header, unless the handler had already started its
+// response.
+func HandleErrors(handler ErrorHandler) Handler {
+	return func(w *response.Writer, req *request.Request) {
+		handlerErr := handler(w, req)
+		if handlerErr == nil {
+			return
+		}
+		if w.WriterState != response.ReadyForStatusLine {
+			log.Printf("Handler failed after starting the response: %v\n", handlerErr)
+			return
+		}
+		handlerErr.write(w, req)
+	}
+}
+
+var errorMediaTypes = []string{"text/plain", "text/html", "application/json", "application/problem+json"}
+
+func (e *HandlerError) write(w *response.Writer, req *request.Request) {
+	accept, _ := req.Headers.Get("Accept")
+	mediaType := negotiate(accept, errorMediaTypes)
+	if mediaType == "" {
+		// the client will get an error either way, no point in a 406
+		mediaType = "text/plain"
+	}
+	if e.Problem || mediaType == "application/problem+json" {
+		w.WriteProblem(response.Problem{Status: e.StatusCode, Detail: e.Message})
+		return
+	}
+
+	var body []byte
+	reason := response.StatusText(e.StatusCode)
+	switch mediaType {
+	case "text/html":
+		body = []byte(fmt.Sprintf(`<html>
+<head>
+<title>%d %s</title>
+</head>
+<body>
+<h1>%s</h1>
+<p>%s</p>
+</body>
+</html>
+`, e.StatusCode, reason, reason, html.EscapeString(e.Message)))
+	case "application/json":
+		body, _ = json.Marshal(struct {
+			Status  response.StatusCode `json:"status"`
+			Error   string              `json:"error"`
+			Message string              `json:"message"`
+		}{e.StatusCode, reason, e.Message})
+	default:
+		body = []byte(e.Message + "\n")
+	}
+
+	h := response.GetDefaultHeaders(len(body))
+	h.Set("Content-Type", mediaType)
+	w.WriteStatusLine(e.StatusCode)
+	w.WriteHeaders(h)
+	w.WriteBody(body)
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"MyOwnHTTP/internal/request"
+	"MyOwnHTTP/internal/response"
+)
+
+func TestHandleErrors(t *testing.T) {
+	notFound := HandleErrors(func(w *response.Writer, req *request.Request) *HandlerError {
+		return &HandlerError{StatusCode: response.StatusNotFound, Message: "no <such> thing"}
+	})
+	cases := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", "text/plain", "no <such> thing\n"},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", "text/html", "<p>no &lt;such&gt; thing</p>"},
+		{"application/json", "application/json", `{"status":404,"error":"Not Found","message":"no \u003csuch\u003e thing"}`},
+		{"text/*;q=0.5, application/json;q=0.9", "application/json", `"status":404`},
+		{"image/png", "text/plain", "no <such> thing\n"},
+		{"application/problem+json", "application/problem+json", `{"title":"Not Found","status":404,"detail":"no \u003csuch\u003e thing"}`},
+	}
+	for _, c := range cases {
+		t.Run(c.accept, func(t *testing.T) {
+			raw := "GET / HTTP/1.1\r\nHost: localhost\r\n"
+			if c.accept != "" {
+				raw += "Accept: " + c.accept + "\r\n"
+			}
+			resp, _ := runHandler(t, notFound, raw+"\r\n")
+			assert.Equal(t, 404, resp.StatusCode)
+			assert.Equal(t, c.contentType, resp.Header.Get("Content-Type"))
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(body), c.body)
+		})
+	}
+
+	// Test: Nil error leaves the handler's response alone
+	resp, _ := runHandler(t, HandleErrors(func(w *response.Writer, req *request.Request) *HandlerError {
+		echoTarget(w, req)
+		return nil
+	}), "GET /fine HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Equal(t, 200, resp.StatusCode)
+
+	// Test: Error after the response started writes nothing more
+	req, err := request.RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	HandleErrors(func(w *response.Writer, req *request.Request) *HandlerError {
+		w.WriteStatusLine(response.StatusOK)
+		return &HandlerError{StatusCode: response.StatusInternalServerError, Message: "late"}
+	})(response.NewWriter(&buf), req)
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n", buf.String())
+}
+
+func TestHandleErrorsHead(t *testing.T) {
+	handler := HandleErrors(func(w *response.Writer, req *request.Request) *HandlerError {
+		if req.RequestLine.RequestTarget == "/fine" {
+			echoTarget(w, req)
+			return nil
+		}
+		return &HandlerError{StatusCode: response.StatusNotFound, Message: "no such thing"}
+	})
+	s := startServer(t, handler, Options{})
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// Test: The rendered error keeps its headers but loses its body for
+	// HEAD, whatever the format
+	for _, accept := range []string{"text/plain", "text/html", "application/json", "application/problem+json"} {
+		_, err = conn.Write([]byte("HEAD /missing HTTP/1.1\r\nHost: localhost\r\nAccept: " + accept + "\r\n\r\n" +
+			"GET /fine HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+		require.NoError(t, err)
+		resp, err := http.ReadResponse(r, &http.Request{Method: "HEAD"})
+		require.NoError(t, err)
+		assert.Equal(t, 404, resp.StatusCode, accept)
+		assert.NotEmpty(t, resp.Header.Get("Content-Length"), accept)
+		resp, body := readResponse(t, r)
+		assert.Equal(t, 200, resp.StatusCode, accept)
+		assert.Equal(t, "/fine", body, accept)
+	}
+}
+
+func TestJSONError(t *testing.T) {
+	handler := HandleErrors(func(w *response.Writer, req *request.Request) *HandlerError {
+		var v struct {
+			Name string `json:"name"`
+		}
+		if err := req.DecodeJSONStrict(&v, 32); err != nil {
+			return JSONError(err)
+		}
+		w.WriteJSON(response.StatusOK, nil, v)
+		return nil
+	})
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		statusCode  int
+	}{
+		{"malformed", "application/json", `{"name":`, 400},
+		{"unknown field", "application/json", `{"nom":"a"}`, 400},
+		{"too large", "application/json", `{"name":"` + strings.Repeat("a", 40) + `"}`, 413},
+		{"not json", "text/plain", `{"name":"a"}`, 415},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Test: Problem responses whatever the client accepts
+			raw := "POST / HTTP/1.1\r\nHost: localhost\r\nAccept: text/html\r\n" +
+				"Content-Type: " + c.contentType + "\r\n" +
+				"Content-Length: " + strconv.Itoa(len(c.body)) + "\r\n\r\n" + c.body
+			resp, _ := runHandler(t, handler, raw)
+			assert.Equal(t, c.statusCode, resp.StatusCode)
+			assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+			var problem response.Problem
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+			assert.Equal(t, response.StatusCode(c.statusCode), problem.Status)
+			assert.Equal(t, response.StatusText(problem.Status), problem.Title)
+			assert.NotEmpty(t, problem.Detail)
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"text/plain", "text/html", "application/json"}
+	assert.Equal(t, "text/plain", negotiate("", offers))
+	assert.Equal(t, "text/html", negotiate("text/html", offers))
+	assert.Equal(t, "text/plain", negotiate("*/*", offers))
+	assert.Equal(t, "text/html", negotiate("text/*;q=0.9, text/plain;q=0.1", offers))
+	assert.Equal(t, "application/json", negotiate("*/*;q=0.1, application/json", offers))
+	assert.Equal(t, "", negotiate("text/html;q=0, image/png", []string{"text/html"}))
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// defaultAddr binds the loopback interface on a port the system picks.
+const defaultAddr = "localhost:0"
+
+// openListener sets up what the options ask the server to accept
+// connections on: a listener handed over by the caller, a Unix socket or
+// a TCP address.
+func openListener(options Options) (net.Listener, error) {
+	set := 0
+	for _, ok := range []bool{options.Addr != "", options.UnixSocket != "", options.Listener != nil} {
+		if ok {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, errors.New("only one of Addr, UnixSocket and Listener can be set")
+	}
+
+	switch {
+	case options.Listener != nil:
+		return options.Listener, nil
+	case options.UnixSocket != "":
+		return listenUnix(options.UnixSocket, options.UnixSocketMode)
+	case options.Addr != "":
+		return net.Listen("tcp", options.Addr)
+	default:
+		return net.Listen("tcp", defaultAddr)
+	}
+}
+
+// listenUnix listens on a Unix socket at path, replacing a socket left
+// behind by an earlier run. The socket file is removed again when the
+// listener is closed.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+	return listener, nil
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getOver does one request on a fresh connection and returns the body.
+func getOver(t *testing.T, network, addr string) string {
+	t.Helper()
+	conn, err := net.Dial(network, addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /ok HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+	require.NoError(t, err)
+	_, body := readResponse(t, bufio.NewReader(conn))
+	return body
+}
+
+func TestListenAddr(t *testing.T) {
+	// Test: Default binds loopback on a free port
+	s := startServer(t, echoTarget, Options{})
+	addr := s.Addr().(*net.TCPAddr)
+	assert.True(t, addr.IP.IsLoopback())
+	assert.NotZero(t, addr.Port)
+	assert.Equal(t, "/ok", getOver(t, "tcp", s.Addr().String()))
+
+	// Test: Port 0 on every interface reports the chosen port
+	s = startServer(t, echoTarget, Options{Addr: ":0"})
+	port := s.Addr().(*net.TCPAddr).Port
+	assert.NotZero(t, port)
+	assert.Equal(t, "/ok", getOver(t, "tcp", localAddr(port)))
+
+	// Test: IPv6 addresses
+	if ln, err := net.Listen("tcp", "[::1]:0"); err == nil {
+		ln.Close()
+		s = startServer(t, echoTarget, Options{Addr: "[::1]:0"})
+		assert.Equal(t, "::1", s.Addr().(*net.TCPAddr).IP.String())
+		assert.Equal(t, "/ok", getOver(t, "tcp", s.Addr().String()))
+	}
+
+	// Test: Address already in use is an error instead of an exit
+	_, err := ServeWithOptions(echoTarget, Options{Addr: s.Addr().String()})
+	assert.Error(t, err)
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.sock")
+	s := startServer(t, echoTarget, Options{UnixSocket: path, UnixSocketMode: 0o660})
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o660), info.Mode().Perm())
+	assert.Equal(t, "/ok", getOver(t, "unix", path))
+
+	// Test: A stale socket from an earlier run is replaced
+	require.NoError(t, s.Close())
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	s = startServer(t, echoTarget, Options{UnixSocket: path})
+	assert.Equal(t, "/ok", getOver(t, "unix", path))
+
+	// Test: Regular files are left alone
+	file := filepath.Join(t.TempDir(), "not-a-socket")
+	require.NoError(t, os.WriteFile(file, nil, 0o600))
+	_, err = ServeWithOptions(echoTarget, Options{UnixSocket: file})
+	assert.Error(t, err)
+}
+
+func TestListenExistingListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := startServer(t, echoTarget, Options{Listener: ln})
+	assert.Equal(t, ln.Addr(), s.Addr())
+	assert.Equal(t, "/ok", getOver(t, "tcp", ln.Addr().String()))
+
+	// Test: Conflicting options
+	_, err = ServeWithOptions(echoTarget, Options{Listener: ln, Addr: ":0"})
+	assert.Error(t, err)
+}
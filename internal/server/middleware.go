@@ -0,0 +1,165 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"MyOwnHTTP/internal/headers"
+	"MyOwnHTTP/internal/request"
+	"MyOwnHTTP/internal/response"
+)
+
+// Middleware wraps a handler with behaviour shared across handlers.
+type Middleware func(Handler) Handler
+
+const requestIDHeader = "X-Request-Id"
+
+// Chain wraps handler in the middleware so that the first one listed is
+// the outermost and sees the request first.
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Compose merges several middleware into one, in the same order as Chain.
+func Compose(middlewares ...Middleware) Middleware {
+	return func(next Handler) Handler {
+		return Chain(next, middlewares...)
+	}
+}
+
+// Logging logs every request with its status and how long it took.
+func Logging(next Handler) Handler {
+	return func(w *response.Writer, req *request.Request) {
+		start := time.Now()
+		next(w, req)
+		log.Printf("%s %s %d %s %v\n",
+			req.RequestLine.Method,
+			req.RequestLine.RequestTarget,
+			w.StatusCode,
+			RequestID(req),
+			time.Since(start),
+		)
+	}
+}
+
+// Recover turns a panicking handler into a 500 response. If the handler
+// had already started the response all we can do is leave it unfinished,
+// which makes the server close the connection.
+func Recover(next Handler) Handler {
+	return func(w *response.Writer, req *request.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("Handler panicked: %v\n%s", err, debug.Stack())
+				if w.WriterState == response.ReadyForStatusLine {
+					writeErrorResponse(w, response.StatusInternalServerError, response.StatusText(response.StatusInternalServerError))
+				}
+			}
+		}()
+		next(w, req)
+	}
+}
+
+// RequestIDs makes sure every request carries an X-Request-Id, keeping
+// the one the client sent if any, and echoes it on the response.
+func RequestIDs(next Handler) Handler {
+	return func(w *response.Writer, req *request.Request) {
+		id := RequestID(req)
+		if id == "" {
+			id = newRequestID()
+			req.Headers.Set(requestIDHeader, id)
+		}
+		if w.ExtraHeaders == nil {
+			w.ExtraHeaders = headers.NewHeaders()
+		}
+		w.ExtraHeaders.Set(requestIDHeader, id)
+		next(w, req)
+	}
+}
+
+// RequestID returns the request's X-Request-Id, or "" if it has none.
+func RequestID(req *request.Request) string {
+	id, _ := req.Headers.Get(requestIDHeader)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Timing reports how long each request took to handle, for metrics.
+func Timing(record func(req *request.Request, statusCode response.StatusCode, elapsed time.Duration)) Middleware {
+	return func(next Handler) Handler {
+		return func(w *response.Writer, req *request.Request) {
+			start := time.Now()
+			next(w, req)
+			record(req, w.StatusCode, time.Since(start))
+		}
+	}
+}
+
+// Compress has responses compressed with gzip or deflate for clients that
+// accept either, by Accept-Encoding q-values. Bodies shorter than
+// minSize are sent as they are, as are media types that don't shrink.
+func Compress(minSize int) Middleware {
+	return func(next Handler) Handler {
+		return func(w *response.Writer, req *request.Request) {
+			// a HEAD response has no body to compress, and its headers
+			// have to go out without one
+			if req.RequestLine.Method != "HEAD" {
+				w.Compression = &response.Compression{
+					Encoding: acceptedEncoding(req),
+					MinSize:  minSize,
+				}
+			}
+			next(w, req)
+		}
+	}
+}
+
+// acceptedEncoding picks the encoding for a response, or "" to send it
+// as it is. Without an Accept-Encoding header we don't compress, since
+// older clients leave it out.
+func acceptedEncoding(req *request.Request) string {
+	accept, err := req.Headers.Get("Accept-Encoding")
+	if err != nil || strings.TrimSpace(accept) == "" {
+		return ""
+	}
+	return negotiate(accept, response.Encodings)
+}
+
+// Decompress decodes gzip and deflate request bodies before the handler
+// sees them, within limits so a small upload can't expand without bound.
+// Bodies in other encodings get a 415 listing the ones we take. A
+// streamed body is decoded as the handler reads it, so its errors show
+// up there instead.
+func Decompress(limits request.DecodeLimits) Middleware {
+	return func(next Handler) Handler {
+		return func(w *response.Writer, req *request.Request) {
+			err := req.DecodeBody(limits)
+			switch {
+			case err == nil:
+				next(w, req)
+			case errors.Is(err, request.ErrUnsupportedEncoding):
+				if w.ExtraHeaders == nil {
+					w.ExtraHeaders = headers.NewHeaders()
+				}
+				w.ExtraHeaders.Set("Accept-Encoding", strings.Join(request.DecodableEncodings, ", "))
+				writeErrorResponse(w, response.StatusUnsupportedMediaType, err.Error())
+			case errors.Is(err, request.ErrDecodedBodyTooLarge):
+				writeErrorResponse(w, response.StatusContentTooLarge, err.Error())
+			default:
+				writeErrorResponse(w, response.StatusBadRequest, err.Error())
+			}
+		}
+	}
+}
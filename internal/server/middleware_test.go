@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"MyOwnHTTP/internal/request"
+	"MyOwnHTTP/internal/response"
+)
+
+func runHandler(t *testing.T, handler Handler, raw string) (*http.Response, *response.Writer) {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader(raw))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	w := response.NewWriter(&buf)
+	handler(w, req)
+	resp, err := http.ReadResponse(bufio.NewReader(&buf), nil)
+	require.NoError(t, err)
+	return resp, w
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(w *response.Writer, req *request.Request) {
+				order = append(order, name)
+				next(w, req)
+			}
+		}
+	}
+	handler := Chain(echoTarget, tag("a"), Compose(tag("b"), tag("c")))
+	runHandler(t, handler, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Equal(t, []string{"a", "b", "c"}, order)
+}
+
+func TestRecover(t *testing.T) {
+	// Test: Panic before writing becomes a 500
+	panics := func(w *response.Writer, req *request.Request) {
+		panic("boom")
+	}
+	resp, w := runHandler(t, Recover(panics), "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Equal(t, 500, resp.StatusCode)
+	assert.Equal(t, response.Done, w.WriterState)
+
+	// Test: Panic mid-response leaves it unfinished
+	req, err := request.RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	w = response.NewWriter(&bytes.Buffer{})
+	Recover(func(w *response.Writer, req *request.Request) {
+		w.WriteStatusLine(response.StatusOK)
+		panic("boom")
+	})(w, req)
+	assert.Equal(t, response.ReadyForHeader, w.WriterState)
+}
+
+func TestRequestIDs(t *testing.T) {
+	// Test: Generated when missing and visible to the handler
+	var seen string
+	handler := RequestIDs(func(w *response.Writer, req *request.Request) {
+		seen = RequestID(req)
+		echoTarget(w, req)
+	})
+	resp, _ := runHandler(t, handler, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Len(t, seen, 16)
+	assert.Equal(t, seen, resp.Header.Get("X-Request-Id"))
+
+	// Test: Client supplied id is kept
+	resp, _ = runHandler(t, handler, "GET / HTTP/1.1\r\nHost: localhost\r\nX-Request-ID: abc\r\n\r\n")
+	assert.Equal(t, "abc", seen)
+	assert.Equal(t, "abc", resp.Header.Get("X-Request-Id"))
+}
+
+func TestTiming(t *testing.T) {
+	var status response.StatusCode
+	var elapsed time.Duration
+	slow := func(w *response.Writer, req *request.Request) {
+		time.Sleep(10 * time.Millisecond)
+		echoTarget(w, req)
+	}
+	handler := Timing(func(req *request.Request, statusCode response.StatusCode, d time.Duration) {
+		status = statusCode
+		elapsed = d
+	})(slow)
+	runHandler(t, handler, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Equal(t, response.StatusOK, status)
+	assert.GreaterOrEqual(t, elapsed, 10*time.Millisecond)
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("compress me ", 100)
+	handler := Compress(256)(func(w *response.Writer, req *request.Request) {
+		w.WriteStatusLine(response.StatusOK)
+		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		w.WriteBody([]byte(body))
+	})
+	cases := []struct {
+		method         string
+		acceptEncoding string
+		encoding       string
+	}{
+		{"GET", "gzip, deflate", "gzip"},
+		{"GET", "deflate, gzip;q=0.5", "deflate"},
+		{"GET", "br, *;q=0.1", "gzip"},
+		{"GET", "gzip;q=0, deflate;q=0", ""},
+		{"GET", "identity", ""},
+		{"GET", "", ""},
+		{"HEAD", "gzip", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.method+" "+c.acceptEncoding, func(t *testing.T) {
+			raw := c.method + " / HTTP/1.1\r\nHost: localhost\r\n"
+			if c.acceptEncoding != "" {
+				raw += "Accept-Encoding: " + c.acceptEncoding + "\r\n"
+			}
+			resp, _ := runHandler(t, handler, raw+"\r\n")
+			assert.Equal(t, c.encoding, resp.Header.Get("Content-Encoding"))
+			if c.encoding == "" && c.method == "GET" {
+				assert.Equal(t, strconv.Itoa(len(body)), resp.Header.Get("Content-Length"))
+			}
+			if c.method == "GET" {
+				assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte("hello"))
+	gz.Close()
+	bomb := bytes.Buffer{}
+	gz = gzip.NewWriter(&bomb)
+	gz.Write(make([]byte, 1<<20))
+	gz.Close()
+
+	handler := Decompress(request.DecodeLimits{})(func(w *response.Writer, req *request.Request) {
+		w.WriteStatusLine(response.StatusOK)
+		w.WriteHeaders(response.GetDefaultHeaders(len(req.Body)))
+		w.WriteBody(req.Body)
+	})
+	cases := []struct {
+		name           string
+		encoding       string
+		body           []byte
+		statusCode     int
+		acceptEncoding string
+	}{
+		{"gzip", "gzip", compressed.Bytes(), 200, ""},
+		{"unsupported", "br", []byte("hello"), 415, "gzip, deflate"},
+		{"corrupt", "gzip", []byte("hello"), 400, ""},
+		{"bomb", "gzip", bomb.Bytes(), 413, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			raw := "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Encoding: " + c.encoding + "\r\n" +
+				"Content-Length: " + strconv.Itoa(len(c.body)) + "\r\n\r\n" + string(c.body)
+			resp, _ := runHandler(t, handler, raw)
+			assert.Equal(t, c.statusCode, resp.StatusCode)
+			assert.Equal(t, c.acceptEncoding, resp.Header.Get("Accept-Encoding"))
+			if c.statusCode == 200 {
+				// Test: Handler sees the decoded body
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, "hello", string(body))
+			}
+		})
+	}
+}
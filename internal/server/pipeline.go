@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+
+	"MyOwnHTTP/internal/response"
+)
+
+// maxPipelinedRequests bounds how many requests on one connection may be
+// in flight before we stop reading new ones.
+const maxPipelinedRequests = 16
+
+// maxSlotBuffer bounds how much of a response is held back while earlier
+// responses are still going out. A handler writing more waits for its
+// turn, so a connection holds at most maxPipelinedRequests of these.
+const maxSlotBuffer = 64 * 1024
+
+// pipelineSlot holds the response to one pipelined request. Writes are
+// buffered until every earlier response on the connection has gone out,
+// after which they pass straight through.
+type pipelineSlot struct {
+	mu sync.Mutex
+	// turn is signalled when the slot becomes active or is abandoned
+	turn   *sync.Cond
+	dst    io.Writer
+	buf    bytes.Buffer
+	writer *response.Writer
+	done   chan struct{}
+	// err is the first error writing to dst, after which the connection
+	// can't be trusted with another response
+	err error
+}
+
+func newPipelineSlot(method string, keepAlive bool) *pipelineSlot {
+	slot := &pipelineSlot{done: make(chan struct{})}
+	slot.turn = sync.NewCond(&slot.mu)
+	slot.writer = response.NewWriter(slot)
+	slot.writer.KeepAlive = keepAlive
+	// a response to HEAD has the headers a GET would get but no body,
+	// RFC 9110 section 9.3.2
+	slot.writer.OmitBody = method == "HEAD"
+	return slot
+}
+
+func (p *pipelineSlot) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	written := 0
+	for p.dst == nil {
+		if p.err != nil {
+			return written, p.err
+		}
+		room := min(maxSlotBuffer-p.buf.Len(), len(b))
+		if room <= 0 {
+			p.turn.Wait()
+			continue
+		}
+		p.buf.Write(b[:room])
+		written += room
+		b = b[room:]
+		if len(b) == 0 {
+			return written, nil
+		}
+	}
+	n, err := p.dst.Write(b)
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return written + n, err
+}
+
+func (p *pipelineSlot) writeErr() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// activate flushes whatever the handler buffered and switches the slot
+// to writing directly to dst.
+func (p *pipelineSlot) activate(dst io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.buf.Len() > 0 {
+		if _, err := dst.Write(p.buf.Bytes()); err != nil {
+			return err
+		}
+		p.buf.Reset()
+	}
+	p.dst = dst
+	p.turn.Broadcast()
+	return nil
+}
+
+// abandon fails the slot's writes, for when the connection is gone
+// before the slot's turn came.
+func (p *pipelineSlot) abandon() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = net.ErrClosed
+	}
+	p.turn.Broadcast()
+}
+
+func (p *pipelineSlot) finish() {
+	close(p.done)
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineSlotBuffer(t *testing.T) {
+	// Test: Writes past the cap wait for the slot's turn
+	slot := newPipelineSlot("GET", true)
+	body := bytes.Repeat([]byte("x"), maxSlotBuffer+10)
+	wrote := make(chan int)
+	go func() {
+		n, _ := slot.Write(body)
+		wrote <- n
+	}()
+	select {
+	case <-wrote:
+		t.Fatal("write past the cap didn't wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	slot.mu.Lock()
+	assert.Equal(t, maxSlotBuffer, slot.buf.Len())
+	slot.mu.Unlock()
+
+	var dst bytes.Buffer
+	require.NoError(t, slot.activate(&dst))
+	assert.Equal(t, len(body), <-wrote)
+	assert.Equal(t, body, dst.Bytes())
+
+	// Test: Abandoning the slot fails a waiting write
+	slot = newPipelineSlot("GET", true)
+	errs := make(chan error)
+	go func() {
+		_, err := slot.Write(body)
+		errs <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	slot.abandon()
+	assert.ErrorIs(t, <-errs, net.ErrClosed)
+}
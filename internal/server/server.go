@@ -1,34 +1,198 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net"
+	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"MyOwnHTTP/internal/request"
 	"MyOwnHTTP/internal/response"
 )
 
+const (
+	defaultIdleTimeout         = 60 * time.Second
+	defaultReadHeaderTimeout   = 10 * time.Second
+	defaultReadBodyTimeout     = 30 * time.Second
+	defaultWriteTimeout        = 30 * time.Second
+	defaultRetryAfter          = time.Second
+	defaultMaxRequestsPerConn  = 100
+	defaultMaxRequestLineBytes = 8 * 1024
+	defaultMaxHeaderBytes      = 64 * 1024
+	defaultMaxHeaderCount      = 100
+	defaultMaxBodyBytes        = 10 * 1024 * 1024
+)
+
 type Server struct {
 	Up        atomic.Bool
 	ConnCount atomic.Int32
 	Listener  net.Listener
 	Handler   Handler
+	options   Options
+
+	mu           sync.Mutex
+	conns        map[*trackedConn]struct{}
+	shuttingDown atomic.Bool
+
+	connLimit    limiter
+	requestLimit limiter
 }
 
+// Options tunes how the server treats connections. Zero values fall back
+// to the package defaults.
+type Options struct {
+	// IdleTimeout is how long a keep-alive connection may wait for the
+	// next request before it is closed.
+	IdleTimeout time.Duration
+	// ReadHeaderTimeout is how long a client has to send the request line
+	// and headers, counted from the first byte. Running out of time is
+	// answered with 408 Request Timeout. Negative turns it off, as for
+	// the other timeouts below.
+	ReadHeaderTimeout time.Duration
+	// ReadBodyTimeout is how long the body may stall once the headers are
+	// in. Each read that gets part of it starts the timeout over, so a
+	// long upload is fine as long as it keeps coming. With StreamBodies
+	// the handler sees the timeout as a read error instead of a 408, and
+	// a handler that waits longer than this between reads runs into it
+	// too.
+	ReadBodyTimeout time.Duration
+	// WriteTimeout is how long a write of the response may stall before
+	// the connection is given up on. It starts over with every write, so
+	// it limits a client that stops reading, not the length of a
+	// download.
+	WriteTimeout time.Duration
+	// MaxConns caps how many connections are served at once. Zero means
+	// no cap.
+	MaxConns int
+	// MaxConcurrentRequests caps how many handlers run at once across all
+	// connections. Zero means no cap.
+	MaxConcurrentRequests int
+	// LimitWait is how long a new connection or request waits for room
+	// when a cap is reached. After that, or right away when it is zero, it
+	// is answered with 503 Service Unavailable.
+	LimitWait time.Duration
+	// RetryAfter is what the Retry-After header of those 503s suggests,
+	// in whole seconds.
+	RetryAfter time.Duration
+	// MaxRequestsPerConn caps how many requests are served on a single
+	// connection before it is closed.
+	MaxRequestsPerConn int
+	// StreamBodies hands requests to the handler as soon as the headers
+	// are parsed. The body is read from Request.BodyReader instead of
+	// being buffered into Request.Body.
+	StreamBodies bool
+	// Limits bounds the size of incoming requests. Zero fields use the
+	// defaults and negative ones turn that limit off. With StreamBodies a
+	// zero MaxBodyBytes means no limit, as the body isn't buffered.
+	Limits request.Limits
+	// TLSConfig switches the server to HTTPS. It needs Certificates or
+	// GetCertificate set; CertStore.TLSConfig provides one that picks
+	// certificates by SNI and reloads them from disk.
+	TLSConfig *tls.Config
+	// Addr is the host:port to listen on, like ":8080" or "[::1]:0". With
+	// port 0 the system picks one and Server.Addr reports it.
+	Addr string
+	// UnixSocket is the path of a Unix domain socket to listen on instead
+	// of a TCP address.
+	UnixSocket string
+	// UnixSocketMode sets the permissions of the socket file. Zero leaves
+	// them to the umask.
+	UnixSocketMode os.FileMode
+	// Listener is an already open listener to serve on, for instance one
+	// passed down by systemd socket activation. The server takes it over
+	// and closes it on shutdown.
+	Listener net.Listener
+}
+
+// Serve listens on the given port of the loopback interface.
 func Serve(port int, handler Handler) (*Server, error) {
+	return ServeWithOptions(handler, Options{Addr: localAddr(port)})
+}
+
+// ServeTLS serves HTTPS with a single certificate and key pair, picking
+// up changes to the files without a restart.
+func ServeTLS(port int, handler Handler, certFile, keyFile string) (*Server, error) {
+	certs := NewCertStore()
+	if err := certs.Add(certFile, keyFile); err != nil {
+		return nil, err
+	}
+	return ServeWithOptions(handler, Options{Addr: localAddr(port), TLSConfig: certs.TLSConfig()})
+}
+
+// ServeWithOptions starts serving on whatever the options point at,
+// loopback on a free port by default.
+func ServeWithOptions(handler Handler, options Options) (*Server, error) {
 	server := Server{}
 	server.Handler = handler
-	listener, err := net.Listen("tcp", "localhost:"+strconv.Itoa(port))
+	server.options = options.withDefaults()
+	server.connLimit = newLimiter(options.MaxConns)
+	server.requestLimit = newLimiter(options.MaxConcurrentRequests)
+	listener, err := openListener(options)
 	if err != nil {
-		log.Fatalf("Failed to create a listener on the given address: %v\n", err)
+		return nil, fmt.Errorf("failed to create a listener: %w", err)
+	}
+	if options.TLSConfig != nil {
+		listener = tls.NewListener(listener, serverTLSConfig(options.TLSConfig))
 	}
 	server.Listener = listener
+	server.Up.Store(true)
 	go server.listen()
 	return &server, nil
 }
 
+func localAddr(port int) string {
+	return net.JoinHostPort("localhost", strconv.Itoa(port))
+}
+
+// Addr is the address the server accepts connections on, with the actual
+// port when the system picked it.
+func (s *Server) Addr() net.Addr {
+	return s.Listener.Addr()
+}
+
+func (o Options) withDefaults() Options {
+	if o.IdleTimeout <= 0 {
+		o.IdleTimeout = defaultIdleTimeout
+	}
+	o.ReadHeaderTimeout = limitOrDefault(o.ReadHeaderTimeout, defaultReadHeaderTimeout)
+	o.ReadBodyTimeout = limitOrDefault(o.ReadBodyTimeout, defaultReadBodyTimeout)
+	o.WriteTimeout = limitOrDefault(o.WriteTimeout, defaultWriteTimeout)
+	if o.RetryAfter <= 0 {
+		o.RetryAfter = defaultRetryAfter
+	}
+	if o.MaxRequestsPerConn <= 0 {
+		o.MaxRequestsPerConn = defaultMaxRequestsPerConn
+	}
+	o.Limits.MaxRequestLineBytes = limitOrDefault(o.Limits.MaxRequestLineBytes, defaultMaxRequestLineBytes)
+	o.Limits.MaxHeaderBytes = limitOrDefault(o.Limits.MaxHeaderBytes, defaultMaxHeaderBytes)
+	o.Limits.MaxHeaderCount = limitOrDefault(o.Limits.MaxHeaderCount, defaultMaxHeaderCount)
+	// a streamed body isn't held in memory, so it only gets a cap when
+	// one is asked for
+	if !o.StreamBodies || o.Limits.MaxBodyBytes != 0 {
+		o.Limits.MaxBodyBytes = limitOrDefault(o.Limits.MaxBodyBytes, defaultMaxBodyBytes)
+	}
+	return o
+}
+
+func limitOrDefault[T int | int64 | time.Duration](limit, def T) T {
+	if limit == 0 {
+		return def
+	}
+	if limit < 0 {
+		return 0
+	}
+	return limit
+}
+
 func (s *Server) Close() error {
 	err := s.Listener.Close()
 	if err != nil && s.Up.Load() {
@@ -39,27 +203,228 @@ func (s *Server) Close() error {
 }
 
 func (s *Server) listen() {
+	var backoff acceptBackoff
 	for {
 		conn, err := s.Listener.Accept()
 		if err != nil {
-			log.Printf("Not able to accept the incoming request: %v\n", err)
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			delay := backoff.wait()
+			log.Printf("Not able to accept the incoming request, retrying in %v: %v\n", delay, err)
+			continue
 		}
-		s.ConnCount.Store(s.ConnCount.Load() + 1)
-		go s.handle(conn)
+		backoff.reset()
+		if !s.connLimit.acquire(s.options.LimitWait) {
+			go s.rejectConn(conn)
+			continue
+		}
+		s.ConnCount.Add(1)
+		go func() {
+			defer s.connLimit.release()
+			s.handle(conn)
+		}()
 	}
 }
 
 func (s *Server) handle(conn net.Conn) {
+	tc := s.trackConn(conn)
+	defer s.ConnCount.Add(-1)
+	defer s.untrackConn(tc)
 	defer conn.Close()
-	currRequest, err := request.RequestFromReader(conn)
+
+	timer := &requestTimer{tc: tc, headerTimeout: s.options.ReadHeaderTimeout}
+	queue := make(chan *pipelineSlot, maxPipelinedRequests)
+	written := make(chan struct{})
+	go func() {
+		s.writeResponses(tc, timer, queue)
+		close(written)
+	}()
+	defer func() {
+		close(queue)
+		<-written
+	}()
+
+	tlsState, err := s.handshake(conn)
+	if err != nil {
+		log.Printf("TLS handshake failed: %v\n", err)
+		return
+	}
+
+	// handlers of this connection's requests that haven't finished yet
+	var handlers sync.WaitGroup
+
+	reader := request.NewReader(timer)
+	reader.Limits = s.options.Limits
+	for served := 1; ; served++ {
+		if reader.Buffered() > 0 {
+			// a pipelined request is already partly in
+			timer.start()
+		} else {
+			timer.waitForRequest(s.options.IdleTimeout)
+		}
+		// a new connection may already have its first request on the way,
+		// and a pipelined one may be in the buffer, so those get read
+		if s.shuttingDown.Load() && served > 1 && reader.Buffered() == 0 {
+			return
+		}
+		currRequest, err := s.readRequest(timer, reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if isTimeout(err) {
+				if timer.hasStarted() {
+					log.Printf("Timed out reading request: %v\n", err)
+					tc.inFlight.Add(1)
+					rejectRequest(queue, response.StatusRequestTimeout, response.StatusText(response.StatusRequestTimeout))
+				}
+				return
+			}
+			log.Printf("Failed to read request: %v\n", err)
+			if statusCode, ok := requestErrorStatus(err); ok {
+				tc.inFlight.Add(1)
+				rejectRequest(queue, statusCode, err.Error())
+			}
+			return
+		}
+		currRequest.TLS = tlsState
+
+		keepAlive := wantsKeepAlive(currRequest) && served < s.options.MaxRequestsPerConn && !s.shuttingDown.Load()
+		// only safe methods may be handled in parallel, RFC 9112 section
+		// 9.3.2, so anything else waits for the handlers before it and
+		// holds back the ones after it
+		safe := isSafeMethod(currRequest.RequestLine.Method)
+		if !safe {
+			handlers.Wait()
+		}
+		slot := newPipelineSlot(currRequest.RequestLine.Method, keepAlive)
+		tc.inFlight.Add(1)
+		tc.served.Store(true)
+		queue <- slot
+		handlers.Go(func() {
+			defer slot.finish()
+			if !s.requestLimit.acquire(s.options.LimitWait) {
+				writeServiceUnavailable(slot.writer, s.options.RetryAfter)
+				return
+			}
+			defer s.requestLimit.release()
+			defer func() {
+				if err := currRequest.RemoveFormFiles(); err != nil {
+					log.Printf("Failed to remove uploaded files: %v\n", err)
+				}
+			}()
+			s.Handler(slot.writer, currRequest)
+		})
+		if !keepAlive {
+			return
+		}
+		if !safe {
+			<-slot.done
+		}
+		if currRequest.BodyReader != nil {
+			// the next request sits behind this body on the wire
+			<-slot.done
+			if err := currRequest.BodyReader.Close(); err != nil {
+				log.Printf("Failed to drain the request body: %v\n", err)
+				return
+			}
+		}
+	}
+}
+
+// handshake completes the TLS handshake up front so its outcome is known
+// before the first request. It returns nil for plaintext connections.
+func (s *Server) handshake(conn net.Conn) (*tls.ConnectionState, error) {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.options.IdleTimeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	state := tlsConn.ConnectionState()
+	return &state, nil
+}
+
+// readRequest reads the headers and then, unless bodies are streamed to
+// the handler, the body, each under its own timeout.
+func (s *Server) readRequest(timer *requestTimer, reader *request.Reader) (*request.Request, error) {
+	req, err := reader.ReadHeaders()
 	if err != nil {
-		log.Fatalf("Failed to read reqeust: %v\n", err)
+		return nil, err
+	}
+	timer.readBody(s.options.ReadBodyTimeout)
+	if s.options.StreamBodies {
+		reader.StreamBody(req)
+		return req, nil
+	}
+	if err := reader.ReadBody(req); err != nil {
+		return nil, err
 	}
+	return req, nil
+}
+
+// writeResponses sends the responses of a connection in the order their
+// requests arrived, however the handlers finish.
+func (s *Server) writeResponses(tc *trackedConn, timer *requestTimer, queue <-chan *pipelineSlot) {
+	conn := tc.conn
+	dst := deadlineWriter{conn: conn, timeout: s.options.WriteTimeout}
+	for slot := range queue {
+		if err := slot.activate(dst); err != nil {
+			log.Printf("Failed to write the response: %v\n", err)
+			conn.Close()
+			break
+		}
+		<-slot.done
+		if tc.inFlight.Add(-1) == 0 {
+			timer.responsesSent()
+		}
+		if err := slot.writeErr(); err != nil {
+			log.Printf("Failed to write the response: %v\n", err)
+			conn.Close()
+			break
+		}
+		log.Println("Response successfully sent")
+		if slot.writer.WriterState != response.Done || !slot.writer.KeepAlive {
+			// closing unblocks the reader so the queue gets closed
+			conn.Close()
+			break
+		}
+	}
+	for slot := range queue {
+		slot.abandon()
+	}
+}
 
-	writer := response.NewWriter(conn)
-	s.Handler(writer, currRequest)
-	log.Println("Response successfully sent")
-	// err = conn.Write(writer.Buffer)
+// isSafeMethod reports the methods RFC 9110 section 9.2.1 defines as
+// safe, which don't change anything on the server.
+func isSafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
+
+// wantsKeepAlive reports whether the client is willing to reuse the
+// connection. HTTP/1.1 connections persist unless told otherwise.
+func wantsKeepAlive(req *request.Request) bool {
+	value, err := req.Headers.Get("Connection")
+	if err != nil {
+		return true
+	}
+	for _, token := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(token), "close") {
+			return false
+		}
+	}
+	return true
+}
 
-	s.ConnCount.Store(s.ConnCount.Load() - 1)
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"MyOwnHTTP/internal/request"
+	"MyOwnHTTP/internal/response"
+)
+
+func echoTarget(w *response.Writer, req *request.Request) {
+	body := []byte(req.RequestLine.RequestTarget)
+	w.WriteStatusLine(response.StatusOK)
+	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	w.WriteBody(body)
+}
+
+func startServer(t *testing.T, handler Handler, options Options) *Server {
+	t.Helper()
+	s, err := ServeWithOptions(handler, options)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func readResponse(t *testing.T, r *bufio.Reader) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestKeepAlive(t *testing.T) {
+	s := startServer(t, echoTarget, Options{MaxRequestsPerConn: 3})
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// Test: Several requests on one connection
+	for i := 1; i <= 3; i++ {
+		_, err = conn.Write([]byte("GET /" + strconv.Itoa(i) + " HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+		require.NoError(t, err)
+		resp, body := readResponse(t, r)
+		assert.Equal(t, "/"+strconv.Itoa(i), body)
+		// Test: Max requests per connection reached on the last one
+		assert.Equal(t, i == 3, resp.Close)
+	}
+	_, err = r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Test: Client asks to close
+	conn, err = net.Dial("tcp", s.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	r = bufio.NewReader(conn)
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+	require.NoError(t, err)
+	resp, _ := readResponse(t, r)
+	assert.True(t, resp.Close)
+	_, err = r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestKeepAliveIdleTimeout(t *testing.T) {
+	s := startServer(t, echoTarget, Options{IdleTimeout: 50 * time.Millisecond})
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	readResponse(t, r)
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, err = r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestHeadResponses(t *testing.T) {
+	handler := func(w *response.Writer, req *request.Request) {
+		if req.RequestLine.RequestTarget == "/nowhere" {
+			writeErrorResponse(w, response.StatusNotFound, "Not Found")
+			return
+		}
+		echoTarget(w, req)
+	}
+	s := startServer(t, handler, Options{})
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// Test: A HEAD response has no body, so the next response follows it
+	// straight away on the same connection
+	_, err = conn.Write([]byte("HEAD /nowhere HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	resp, err := http.ReadResponse(r, &http.Request{Method: "HEAD"})
+	require.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.Equal(t, "10", resp.Header.Get("Content-Length"))
+	assert.False(t, resp.Close)
+
+	_, err = conn.Write([]byte("GET /next HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	resp, body := readResponse(t, r)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "/next", body)
+}
+
+func TestPipelinedResponsesInOrder(t *testing.T) {
+	// earlier requests take longer, so handlers finish in reverse order
+	handler := func(w *response.Writer, req *request.Request) {
+		delay, _ := strconv.Atoi(req.RequestLine.RequestTarget[1:])
+		time.Sleep(time.Duration(delay) * time.Millisecond)
+		echoTarget(w, req)
+	}
+	s := startServer(t, handler, Options{})
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("GET /60 HTTP/1.1\r\nHost: localhost\r\n\r\n" +
+		"GET /30 HTTP/1.1\r\nHost: localhost\r\n\r\n" +
+		"GET /0 HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+	require.NoError(t, err)
+
+	r := bufio.NewReader(conn)
+	for _, want := range []string{"/60", "/30", "/0"} {
+		_, body := readResponse(t, r)
+		assert.Equal(t, want, body)
+	}
+	_, err = r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestPipelinedUnsafeMethods(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+	handler := func(w *response.Writer, req *request.Request) {
+		record("start " + req.RequestLine.RequestTarget)
+		delay, _ := strconv.Atoi(req.RequestLine.RequestTarget[1:])
+		time.Sleep(time.Duration(delay) * time.Millisecond)
+		record("end " + req.RequestLine.RequestTarget)
+		echoTarget(w, req)
+	}
+	s := startServer(t, handler, Options{})
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("GET /40 HTTP/1.1\r\nHost: localhost\r\n\r\n" +
+		"POST /20 HTTP/1.1\r\nHost: localhost\r\nContent-Length: 0\r\n\r\n" +
+		"GET /0 HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+	require.NoError(t, err)
+	r := bufio.NewReader(conn)
+	for _, want := range []string{"/40", "/20", "/0"} {
+		_, body := readResponse(t, r)
+		assert.Equal(t, want, body)
+	}
+
+	// Test: A POST waits for the requests before it and holds back the
+	// ones after it
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"start /40", "end /40", "start /20", "end /20", "start /0", "end /0"}, events)
+}
+
+func TestStreamBodies(t *testing.T) {
+	// echo back the byte count seen through the body reader
+	handler := func(w *response.Writer, req *request.Request) {
+		n, err := io.Copy(io.Discard, req.BodyReader)
+		require.NoError(t, err)
+		body := []byte(strconv.FormatInt(n, 10))
+		w.WriteStatusLine(response.StatusOK)
+		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		w.WriteBody(body)
+	}
+	s := startServer(t, handler, Options{StreamBodies: true})
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	_, err = conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 10\r\n\r\n0123456789"))
+	require.NoError(t, err)
+	_, body := readResponse(t, r)
+	assert.Equal(t, "10", body)
+
+	_, err = conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n0\r\n\r\n"))
+	require.NoError(t, err)
+	_, body = readResponse(t, r)
+	assert.Equal(t, "3", body)
+
+	// Test: The default body limit isn't applied to a streamed body
+	big := strings.Repeat("a", 20<<20)
+	_, err = conn.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: " + strconv.Itoa(len(big)) + "\r\n\r\n" + big))
+	require.NoError(t, err)
+	resp, body := readResponse(t, r)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, strconv.Itoa(len(big)), body)
+}
+
+func TestRequestLimits(t *testing.T) {
+	s := startServer(t, echoTarget, Options{Limits: request.Limits{
+		MaxRequestLineBytes: 64,
+		MaxHeaderBytes:      128,
+		MaxBodyBytes:        16,
+	}})
+	cases := []struct {
+		name       string
+		data       string
+		statusCode int
+	}{
+		{"request line", "GET /" + strings.Repeat("a", 100) + " HTTP/1.1\r\nHost: localhost\r\n\r\n", 414},
+		{"headers", "GET / HTTP/1.1\r\nHost: localhost\r\nX-Big: " + strings.Repeat("a", 200) + "\r\n\r\n", 431},
+		{"body", "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 17\r\n\r\n", 413},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", s.Listener.Addr().String())
+			require.NoError(t, err)
+			defer conn.Close()
+			_, err = conn.Write([]byte(c.data))
+			require.NoError(t, err)
+			resp, _ := readResponse(t, bufio.NewReader(conn))
+			assert.Equal(t, c.statusCode, resp.StatusCode)
+			assert.True(t, resp.Close)
+		})
+	}
+}
+
+func TestMalformedRequests(t *testing.T) {
+	s := startServer(t, echoTarget, Options{})
+	cases := []struct {
+		name       string
+		data       string
+		statusCode int
+	}{
+		{"request line", "GARBAGE\r\n\r\n", 400},
+		{"version", "GET / HTTP/1.0\r\n\r\n", 505},
+		{"header", "GET / HTTP/1.1\r\nHost: localhost\r\nNo Colon Here\r\n\r\n", 400},
+		{"content length", "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: nope\r\n\r\n", 400},
+		{"missing host", "GET / HTTP/1.1\r\n\r\n", 400},
+		{"target", "GET /a%zz HTTP/1.1\r\nHost: localhost\r\n\r\n", 400},
+		{"smuggling", "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 4\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n", 400},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", s.Listener.Addr().String())
+			require.NoError(t, err)
+			defer conn.Close()
+			_, err = conn.Write([]byte(c.data))
+			require.NoError(t, err)
+			resp, _ := readResponse(t, bufio.NewReader(conn))
+			assert.Equal(t, c.statusCode, resp.StatusCode)
+		})
+	}
+
+	// Test: The server is still serving afterwards
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /ok HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	_, body := readResponse(t, bufio.NewReader(conn))
+	assert.Equal(t, "/ok", body)
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// shutdownPollInterval is how often Shutdown checks whether the active
+// connections have finished.
+const shutdownPollInterval = 10 * time.Millisecond
+
+// newConnGrace is how long a connection that hasn't sent a request yet
+// counts as active, since its first request may already be on the way.
+const newConnGrace = 5 * time.Second
+
+// trackedConn is a connection the server is serving. It counts as active
+// from the first byte of a request until its response is sent, and as
+// idle while it is only waiting for the next request. A new connection
+// counts as active for newConnGrace before its first request.
+type trackedConn struct {
+	conn     net.Conn
+	accepted time.Time
+	inFlight atomic.Int32
+	// reading is set while a request is partly in
+	reading atomic.Bool
+	// served is set once the first request has been handed to a handler
+	served atomic.Bool
+}
+
+func (c *trackedConn) idle() bool {
+	if !c.served.Load() && time.Since(c.accepted) < newConnGrace {
+		return false
+	}
+	return c.inFlight.Load() == 0 && !c.reading.Load()
+}
+
+func (s *Server) trackConn(conn net.Conn) *trackedConn {
+	tc := &trackedConn{conn: conn, accepted: time.Now()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns == nil {
+		s.conns = map[*trackedConn]struct{}{}
+	}
+	s.conns[tc] = struct{}{}
+	return tc
+}
+
+func (s *Server) untrackConn(tc *trackedConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, tc)
+}
+
+// Shutdown stops accepting connections and waits for the active ones to
+// finish their requests, closing keep-alive connections as soon as they
+// go idle. Connections that haven't sent a request yet get newConnGrace
+// to do so. When ctx is done first the remaining connections are closed
+// forcibly and their number is returned along with the context's error.
+func (s *Server) Shutdown(ctx context.Context) (int, error) {
+	s.shuttingDown.Store(true)
+	if err := s.Close(); err != nil {
+		return 0, err
+	}
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if s.closeIdleConns() == 0 {
+			return 0, nil
+		}
+		select {
+		case <-ctx.Done():
+			return s.closeAllConns(), ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// closeIdleConns wakes up every idle connection so its reader gives up
+// waiting, and reports how many connections are left.
+func (s *Server) closeIdleConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for tc := range s.conns {
+		if tc.idle() {
+			// a deadline in the past fails the pending read, which ends
+			// the connection without cutting off a response
+			tc.conn.SetReadDeadline(time.Now())
+		}
+	}
+	return len(s.conns)
+}
+
+func (s *Server) closeAllConns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for tc := range s.conns {
+		tc.conn.Close()
+	}
+	return len(s.conns)
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"MyOwnHTTP/internal/request"
+	"MyOwnHTTP/internal/response"
+)
+
+func TestShutdown(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	s := startServer(t, func(w *response.Writer, req *request.Request) {
+		if req.RequestLine.RequestTarget == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+		echoTarget(w, req)
+	}, Options{})
+	addr := s.Listener.Addr().String()
+
+	active, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer active.Close()
+	_, err = active.Write([]byte("GET /slow HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	<-started
+
+	idle, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer idle.Close()
+	idleReader := bufio.NewReader(idle)
+	_, err = idle.Write([]byte("GET /fast HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	_, body := readResponse(t, idleReader)
+	assert.Equal(t, "/fast", body)
+
+	type result struct {
+		dropped int
+		err     error
+	}
+	done := make(chan result)
+	go func() {
+		dropped, err := s.Shutdown(context.Background())
+		done <- result{dropped, err}
+	}()
+
+	// Test: Idle keep-alive connections are closed right away
+	_, err = idleReader.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Test: No new connections once shutting down
+	_, err = net.Dial("tcp", addr)
+	assert.Error(t, err)
+
+	// Test: Active requests get to finish
+	select {
+	case <-done:
+		t.Fatal("Shutdown returned before the active request finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	activeReader := bufio.NewReader(active)
+	_, body = readResponse(t, activeReader)
+	assert.Equal(t, "/slow", body)
+	_, err = activeReader.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+
+	res := <-done
+	assert.NoError(t, res.err)
+	assert.Equal(t, 0, res.dropped)
+	assert.Equal(t, int32(0), s.ConnCount.Load())
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 2)
+	s := startServer(t, func(w *response.Writer, req *request.Request) {
+		started <- struct{}{}
+		<-release
+		echoTarget(w, req)
+	}, Options{})
+
+	for range 2 {
+		conn, err := net.Dial("tcp", s.Listener.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+		require.NoError(t, err)
+		<-started
+	}
+
+	// Test: Connections still busy at the deadline are dropped and counted
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	dropped, err := s.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 2, dropped)
+}
+
+func TestShutdownPartialRequest(t *testing.T) {
+	s := startServer(t, echoTarget, Options{})
+	addr := s.Listener.Addr().String()
+
+	finishing, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer finishing.Close()
+	_, err = finishing.Write([]byte("POST /finished HTTP/1.1\r\nHost: localhost\r\nContent-Length: 10\r\n\r\nhello"))
+	require.NoError(t, err)
+	stalled, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer stalled.Close()
+	_, err = stalled.Write([]byte("POST /stalled HTTP/1.1\r\nHost: local"))
+	require.NoError(t, err)
+	for s.ConnCount.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	type result struct {
+		dropped int
+		err     error
+	}
+	done := make(chan result)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	go func() {
+		dropped, err := s.Shutdown(ctx)
+		done <- result{dropped, err}
+	}()
+
+	// Test: A request that is partly in gets to finish
+	time.Sleep(50 * time.Millisecond)
+	_, err = finishing.Write([]byte(" world"))
+	require.NoError(t, err)
+	resp, body := readResponse(t, bufio.NewReader(finishing))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "/finished", body)
+	assert.True(t, resp.Close)
+
+	// Test: One still partial at the deadline is counted as dropped
+	res := <-done
+	assert.ErrorIs(t, res.err, context.DeadlineExceeded)
+	assert.Equal(t, 1, res.dropped)
+}
+
+func TestShutdownNewConn(t *testing.T) {
+	s := startServer(t, echoTarget, Options{})
+	addr := s.Listener.Addr().String()
+
+	// Test: A request sent on a connection accepted just before Shutdown
+	// still gets its response
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	for s.ConnCount.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	_, err = conn.Write([]byte("GET /early HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	dropped, err := s.Shutdown(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, dropped)
+	resp, body := readResponse(t, bufio.NewReader(conn))
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "/early", body)
+	assert.True(t, resp.Close)
+}
+
+func TestShutdownNewConnDeadline(t *testing.T) {
+	s := startServer(t, echoTarget, Options{})
+
+	// Test: A new connection that never sends anything is waited on, and
+	// counted as dropped at the deadline
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	for s.ConnCount.Load() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	dropped, err := s.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, dropped)
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// requestTimer moves a connection's read deadline along as a request
+// comes in: the idle timeout applies until the first byte arrives, the
+// header timeout from then on until the headers are complete. The
+// header timeout counts from the first byte rather than per read, so a
+// client dribbling a byte at a time can't hold the connection open. The
+// idle timeout only starts once every response so far has been sent,
+// since a client won't send more while it waits on a slow handler. The
+// body timeout is pushed back by every read that gets something, so a
+// long upload only fails once it stalls.
+type requestTimer struct {
+	tc            *trackedConn
+	headerTimeout time.Duration
+
+	mu          sync.Mutex
+	started     bool
+	inBody      bool
+	idleTimeout time.Duration
+	bodyTimeout time.Duration
+}
+
+func (t *requestTimer) Read(b []byte) (int, error) {
+	n, err := t.tc.conn.Read(b)
+	if n > 0 {
+		t.progress()
+	}
+	return n, err
+}
+
+// progress moves the deadline along after a read that got something.
+func (t *requestTimer) progress() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case !t.started:
+		t.startLocked()
+	case t.inBody:
+		t.tc.conn.SetReadDeadline(deadline(t.bodyTimeout))
+	}
+}
+
+// waitForRequest arms the idle timeout for the next request, or leaves
+// it to responsesSent while responses are still pending.
+func (t *requestTimer) waitForRequest(idleTimeout time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.started = false
+	t.inBody = false
+	t.tc.reading.Store(false)
+	t.idleTimeout = idleTimeout
+	if t.tc.inFlight.Load() > 0 {
+		t.tc.conn.SetReadDeadline(time.Time{})
+	} else {
+		t.tc.conn.SetReadDeadline(deadline(idleTimeout))
+	}
+}
+
+// responsesSent arms the idle timeout once the last pending response is
+// out, unless the next request has already started.
+func (t *requestTimer) responsesSent() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.started {
+		t.tc.conn.SetReadDeadline(deadline(t.idleTimeout))
+	}
+}
+
+// start arms the header timeout, for when part of the request is
+// already in.
+func (t *requestTimer) start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.startLocked()
+}
+
+func (t *requestTimer) startLocked() {
+	t.started = true
+	t.inBody = false
+	// from here on Shutdown waits for the request instead of cutting it
+	// off as idle
+	t.tc.reading.Store(true)
+	t.tc.conn.SetReadDeadline(deadline(t.headerTimeout))
+}
+
+func (t *requestTimer) hasStarted() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.started
+}
+
+// readBody arms the body timeout once the headers are in.
+func (t *requestTimer) readBody(bodyTimeout time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inBody = true
+	t.bodyTimeout = bodyTimeout
+	t.tc.conn.SetReadDeadline(deadline(bodyTimeout))
+}
+
+// maxTimedWrite is the most a deadlineWriter writes under one deadline,
+// so a large body gets a fresh one as the client keeps reading.
+const maxTimedWrite = 32 * 1024
+
+// deadlineWriter gives every write to the connection the write timeout,
+// which then only runs out on a client that stops reading, not on a
+// long download.
+type deadlineWriter struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (d deadlineWriter) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		d.conn.SetWriteDeadline(deadline(d.timeout))
+		n, err := d.conn.Write(b[:min(len(b), maxTimedWrite)])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// deadline turns a timeout into a deadline, where zero means none.
+func deadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"MyOwnHTTP/internal/request"
+	"MyOwnHTTP/internal/response"
+)
+
+// serveFakeConn serves one side of an in-memory connection and returns
+// the client side along with a channel that closes once the server is
+// done with the connection.
+func serveFakeConn(t *testing.T, handler Handler, options Options) (net.Conn, <-chan struct{}) {
+	t.Helper()
+	s := &Server{Handler: handler, options: options.withDefaults()}
+	client, conn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		s.handle(conn)
+		close(done)
+	}()
+	t.Cleanup(func() { client.Close() })
+	return client, done
+}
+
+// dribble writes data a byte at a time the way a slowloris client does,
+// giving up once the server hangs up.
+func dribble(conn net.Conn, data string, delay time.Duration) {
+	for i := range len(data) {
+		if _, err := conn.Write([]byte{data[i]}); err != nil {
+			return
+		}
+		time.Sleep(delay)
+	}
+}
+
+func waitDone(t *testing.T, done <-chan struct{}, within time.Duration) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(within):
+		t.Fatal("connection was not closed in time")
+	}
+}
+
+func TestReadHeaderTimeout(t *testing.T) {
+	client, done := serveFakeConn(t, echoTarget, Options{
+		IdleTimeout:       time.Second,
+		ReadHeaderTimeout: 100 * time.Millisecond,
+	})
+
+	// Test: Slowloris headers that never finish get a 408
+	go dribble(client, "GET / HTTP/1.1\r\nHost: localhost\r\nX-Slow: "+string(make([]byte, 100)), 10*time.Millisecond)
+	resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusRequestTimeout, resp.StatusCode)
+	assert.True(t, resp.Close)
+	waitDone(t, done, time.Second)
+}
+
+func TestReadHeaderTimeoutPerRequest(t *testing.T) {
+	client, done := serveFakeConn(t, echoTarget, Options{
+		IdleTimeout:       time.Second,
+		ReadHeaderTimeout: 200 * time.Millisecond,
+	})
+	r := bufio.NewReader(client)
+
+	// Test: Each request gets the full header timeout, slow but steady
+	// clients are fine
+	for range 3 {
+		go dribble(client, "GET /ok HTTP/1.1\r\nHost: x\r\n\r\n", 3*time.Millisecond)
+		resp, body := readResponse(t, r)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "/ok", body)
+	}
+	client.Close()
+	waitDone(t, done, time.Second)
+}
+
+func TestIdleTimeout(t *testing.T) {
+	client, done := serveFakeConn(t, echoTarget, Options{
+		IdleTimeout:       50 * time.Millisecond,
+		ReadHeaderTimeout: time.Second,
+	})
+
+	// Test: An idle connection is closed without a response
+	waitDone(t, done, time.Second)
+	_, err := client.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestIdleTimeoutAfterSlowHandler(t *testing.T) {
+	slow := func(w *response.Writer, req *request.Request) {
+		time.Sleep(150 * time.Millisecond)
+		echoTarget(w, req)
+	}
+	client, done := serveFakeConn(t, slow, Options{
+		IdleTimeout:       50 * time.Millisecond,
+		ReadHeaderTimeout: time.Second,
+	})
+	r := bufio.NewReader(client)
+
+	// Test: The idle timeout counts from the response, not the request
+	_, err := client.Write([]byte("GET /slow HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	resp, err := http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	io.ReadAll(resp.Body)
+	assert.False(t, resp.Close)
+	_, err = client.Write([]byte("GET /next HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+	require.NoError(t, err)
+	resp, err = http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "/next", string(body))
+
+	waitDone(t, done, time.Second)
+}
+
+func TestReadBodyTimeout(t *testing.T) {
+	client, done := serveFakeConn(t, echoTarget, Options{
+		ReadHeaderTimeout: time.Second,
+		ReadBodyTimeout:   100 * time.Millisecond,
+	})
+
+	// Test: Headers arrive but the body stalls
+	go func() {
+		client.Write([]byte("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 10\r\n\r\nabc"))
+	}()
+	resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusRequestTimeout, resp.StatusCode)
+	waitDone(t, done, time.Second)
+}
+
+func TestReadBodyTimeoutProgress(t *testing.T) {
+	client, done := serveFakeConn(t, echoTarget, Options{ReadBodyTimeout: 100 * time.Millisecond})
+
+	// Test: A body that takes longer than the timeout but keeps coming is
+	// read in full
+	go func() {
+		client.Write([]byte("POST /upload HTTP/1.1\r\nHost: localhost\r\nContent-Length: 10\r\nConnection: close\r\n\r\n"))
+		dribble(client, "0123456789", 30*time.Millisecond)
+	}()
+	resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	waitDone(t, done, time.Second)
+}
+
+func TestWriteTimeout(t *testing.T) {
+	client, done := serveFakeConn(t, echoTarget, Options{WriteTimeout: 100 * time.Millisecond})
+
+	// Test: A client that never reads its response is dropped
+	_, err := client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	waitDone(t, done, time.Second)
+}
+
+func TestWriteTimeoutProgress(t *testing.T) {
+	client, done := serveFakeConn(t, func(w *response.Writer, req *request.Request) {
+		w.WriteStatusLine(response.StatusOK)
+		h := response.GetDefaultHeaders(0)
+		h.Del("Content-Length")
+		h.Set("Transfer-Encoding", "chunked")
+		w.WriteHeaders(h)
+		for range 5 {
+			time.Sleep(40 * time.Millisecond)
+			w.WriteChunkedBody([]byte("part\n"))
+		}
+		w.WriteChunkedBodyDone(nil)
+	}, Options{WriteTimeout: 100 * time.Millisecond})
+
+	// Test: A response that keeps coming is sent in full, however long
+	// that takes in total
+	_, err := client.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+	require.NoError(t, err)
+	resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("part\n", 5), string(body))
+	waitDone(t, done, time.Second)
+}
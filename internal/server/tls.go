@@ -0,0 +1,149 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CertStore hands out certificates during the TLS handshake. It picks
+// one by the SNI server name and reloads it from disk whenever its files
+// change, so certificates can be renewed without a restart.
+type CertStore struct {
+	mu       sync.RWMutex
+	byName   map[string]*certFiles
+	fallback *certFiles
+}
+
+// certFiles is one certificate and key pair on disk along with the
+// version of it that was last loaded.
+type certFiles struct {
+	mu       sync.Mutex
+	certFile string
+	keyFile  string
+	modTime  time.Time
+	cert     *tls.Certificate
+}
+
+func NewCertStore() *CertStore {
+	return &CertStore{byName: map[string]*certFiles{}}
+}
+
+// Add loads a certificate and key pair and serves it for the given
+// server names, or for the names in the certificate itself when none are
+// given. Names may be wildcards like *.example.com. The first pair added
+// is used for clients that don't send a name we know.
+func (c *CertStore) Add(certFile, keyFile string, names ...string) error {
+	files := &certFiles{certFile: certFile, keyFile: keyFile}
+	cert, err := files.get()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		names = cert.Leaf.DNSNames
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, name := range names {
+		c.byName[strings.ToLower(name)] = files
+	}
+	if c.fallback == nil {
+		c.fallback = files
+	}
+	return nil
+}
+
+// GetCertificate picks the certificate for a handshake. It fits
+// tls.Config.GetCertificate.
+func (c *CertStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	files := c.lookup(strings.ToLower(hello.ServerName))
+	c.mu.RUnlock()
+	if files == nil {
+		return nil, errors.New("no certificates configured")
+	}
+	return files.get()
+}
+
+func (c *CertStore) lookup(name string) *certFiles {
+	if files, ok := c.byName[name]; ok {
+		return files
+	}
+	if _, parent, ok := strings.Cut(name, "."); ok {
+		if files, ok := c.byName["*."+parent]; ok {
+			return files
+		}
+	}
+	return c.fallback
+}
+
+// TLSConfig returns a server config that takes its certificates from the
+// store.
+func (c *CertStore) TLSConfig() *tls.Config {
+	return &tls.Config{GetCertificate: c.GetCertificate}
+}
+
+// get returns the certificate, loading it again first if either file
+// changed since the last load. If a reload fails we keep serving the
+// old certificate rather than failing handshakes.
+func (f *certFiles) get() (*tls.Certificate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	modTime, err := f.latestModTime()
+	if err != nil {
+		if f.cert != nil {
+			return f.cert, nil
+		}
+		return nil, err
+	}
+	if f.cert != nil && modTime.Equal(f.modTime) {
+		return f.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+	if err == nil {
+		cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	}
+	if err != nil {
+		if f.cert != nil {
+			return f.cert, nil
+		}
+		return nil, fmt.Errorf("loading %s: %w", f.certFile, err)
+	}
+	f.cert = &cert
+	f.modTime = modTime
+	return f.cert, nil
+}
+
+func (f *certFiles) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{f.certFile, f.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// serverTLSConfig fills in what every HTTP/1.1 server config needs
+// without touching the caller's copy.
+func serverTLSConfig(config *tls.Config) *tls.Config {
+	config = config.Clone()
+	if len(config.NextProtos) == 0 {
+		config.NextProtos = []string{"http/1.1"}
+	}
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	return config
+}
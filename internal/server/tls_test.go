@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"MyOwnHTTP/internal/request"
+	"MyOwnHTTP/internal/response"
+)
+
+// writeSelfSignedCert writes a fresh self-signed certificate for names
+// and its key into dir, and returns the file paths and the certificate.
+func writeSelfSignedCert(t *testing.T, dir string, serial int64, names ...string) (string, string, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: names[0]},
+		DNSNames:              names,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, names[0]+".crt")
+	keyFile := filepath.Join(dir, names[0]+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile, cert
+}
+
+func tlsInfo(w *response.Writer, req *request.Request) {
+	body := []byte("plaintext")
+	if req.TLS != nil {
+		body = []byte(fmt.Sprintf("%s %s %d",
+			tls.VersionName(req.TLS.Version),
+			req.TLS.ServerName,
+			len(req.TLS.PeerCertificates),
+		))
+	}
+	w.WriteStatusLine(response.StatusOK)
+	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	w.WriteBody(body)
+}
+
+// dialTLS does a request over TLS and returns the certificate the server
+// presented along with the response body.
+func dialTLS(t *testing.T, s *Server, config *tls.Config) (*x509.Certificate, string) {
+	t.Helper()
+	conn, err := tls.Dial("tcp", s.Listener.Addr().String(), config)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n"))
+	require.NoError(t, err)
+	_, body := readResponse(t, bufio.NewReader(conn))
+	return conn.ConnectionState().PeerCertificates[0], body
+}
+
+func TestServeTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, cert := writeSelfSignedCert(t, dir, 1, "localhost")
+	s, err := ServeTLS(0, tlsInfo, certFile, keyFile)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	// Test: Connection state reaches the handler
+	served, body := dialTLS(t, s, &tls.Config{RootCAs: roots, ServerName: "localhost", MinVersion: tls.VersionTLS13})
+	assert.Equal(t, cert.SerialNumber, served.SerialNumber)
+	assert.Equal(t, "TLS 1.3 localhost 0", body)
+
+	// Test: Renewed certificate is picked up without a restart
+	_, _, renewed := writeSelfSignedCert(t, dir, 2, "localhost")
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	roots.AddCert(renewed)
+	served, _ = dialTLS(t, s, &tls.Config{RootCAs: roots, ServerName: "localhost"})
+	assert.Equal(t, renewed.SerialNumber, served.SerialNumber)
+
+	// Test: Broken files on disk keep the last good certificate
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0o600))
+	served, _ = dialTLS(t, s, &tls.Config{RootCAs: roots, ServerName: "localhost"})
+	assert.Equal(t, renewed.SerialNumber, served.SerialNumber)
+
+	// Test: Plaintext clients fail the handshake
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	_, err = http.ReadResponse(bufio.NewReader(conn), nil)
+	assert.Error(t, err)
+}
+
+func TestCertStoreSNI(t *testing.T) {
+	dir := t.TempDir()
+	certs := NewCertStore()
+	roots := x509.NewCertPool()
+	var issued []*x509.Certificate
+	for i, names := range [][]string{{"a.test"}, {"b.test", "*.b.test"}} {
+		certFile, keyFile, cert := writeSelfSignedCert(t, dir, int64(i+1), names...)
+		require.NoError(t, certs.Add(certFile, keyFile))
+		roots.AddCert(cert)
+		issued = append(issued, cert)
+	}
+	clientCertFile, clientKeyFile, _ := writeSelfSignedCert(t, dir, 3, "client")
+	clientPair, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+	require.NoError(t, err)
+
+	config := certs.TLSConfig()
+	config.ClientAuth = tls.RequestClientCert
+	s := startServer(t, tlsInfo, Options{TLSConfig: config})
+
+	tests := []struct {
+		serverName string
+		want       *x509.Certificate
+	}{
+		{"a.test", issued[0]},
+		{"b.test", issued[1]},
+		// Test: Wildcard names match one label
+		{"www.b.test", issued[1]},
+	}
+	for _, tt := range tests {
+		served, body := dialTLS(t, s, &tls.Config{RootCAs: roots, ServerName: tt.serverName})
+		assert.Equal(t, tt.want.SerialNumber, served.SerialNumber, tt.serverName)
+		assert.Contains(t, body, tt.serverName+" 0")
+	}
+
+	// Test: Unknown names get the first certificate
+	served, _ := dialTLS(t, s, &tls.Config{RootCAs: roots, ServerName: "unknown.test", InsecureSkipVerify: true})
+	assert.Equal(t, issued[0].SerialNumber, served.SerialNumber)
+
+	// Test: Client certificates are exposed to the handler
+	_, body := dialTLS(t, s, &tls.Config{RootCAs: roots, ServerName: "a.test", Certificates: []tls.Certificate{clientPair}})
+	assert.Equal(t, "TLS 1.3 a.test 1", body)
+}
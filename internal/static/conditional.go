@@ -0,0 +1,102 @@
+package static
+
+import (
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"MyOwnHTTP/internal/request"
+)
+
+// httpDateFormat is the IMF-fixdate form of an HTTP date, which is the
+// one we send. Only it is accepted back too, as RFC 9110 lets us.
+const httpDateFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+func formatHTTPDate(t time.Time) string {
+	return t.UTC().Format(httpDateFormat)
+}
+
+func parseHTTPDate(value string) (time.Time, bool) {
+	t, err := time.Parse(httpDateFormat, strings.TrimSpace(value))
+	return t, err == nil
+}
+
+// fileETag builds a strong validator from the modification time and
+// size, which change whenever the file is rewritten in the usual ways.
+func fileETag(info fs.FileInfo) string {
+	return `"` + strconv.FormatInt(info.ModTime().UnixNano(), 16) + "-" + strconv.FormatInt(info.Size(), 16) + `"`
+}
+
+type precondition int
+
+const (
+	preconditionsPass precondition = iota
+	notModified
+	preconditionFailed
+)
+
+// checkPreconditions evaluates the conditional headers in the order RFC
+// 9110 section 13.2.2 gives. Each date header is only looked at when its
+// ETag counterpart is absent, and dates are compared to the second since
+// that's all an HTTP date holds.
+func checkPreconditions(req *request.Request, etag string, modTime time.Time) precondition {
+	modTime = modTime.Truncate(time.Second)
+	if ifMatch, err := req.Headers.Get("If-Match"); err == nil {
+		if !etagMatches(ifMatch, etag, false) {
+			return preconditionFailed
+		}
+	} else if since, err := req.Headers.Get("If-Unmodified-Since"); err == nil {
+		if t, ok := parseHTTPDate(since); ok && modTime.After(t) {
+			return preconditionFailed
+		}
+	}
+
+	if ifNoneMatch, err := req.Headers.Get("If-None-Match"); err == nil {
+		if etagMatches(ifNoneMatch, etag, true) {
+			return notModified
+		}
+	} else if since, err := req.Headers.Get("If-Modified-Since"); err == nil {
+		if t, ok := parseHTTPDate(since); ok && !modTime.After(t) {
+			return notModified
+		}
+	}
+	return preconditionsPass
+}
+
+// rangeApplies checks If-Range: a Range header only counts if the
+// representation is still the one the client has part of.
+func rangeApplies(req *request.Request, etag string, modTime time.Time) bool {
+	ifRange, err := req.Headers.Get("If-Range")
+	if err != nil {
+		return true
+	}
+	ifRange = strings.TrimSpace(ifRange)
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		return etagMatches(ifRange, etag, false)
+	}
+	t, ok := parseHTTPDate(ifRange)
+	return ok && modTime.Truncate(time.Second).Equal(t)
+}
+
+// etagMatches checks a list of entity tags, or "*", against ours. The
+// weak comparison ignores W/ prefixes; the strong one never matches a
+// weak tag.
+func etagMatches(list, etag string, weak bool) bool {
+	for candidate := range strings.SplitSeq(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = candidate[2:]
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package static
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxRanges bounds how many ranges one request can ask for. Past it the
+// Range header is ignored, so lots of tiny or overlapping ranges can't
+// turn a request into much more work than sending the file.
+const maxRanges = 16
+
+var (
+	errUnsatisfiableRange = errors.New("no range overlaps the file")
+	errInvalidRange       = errors.New("invalid Range header")
+)
+
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// parseRange parses a bytes Range header against a file of size bytes,
+// as in RFC 9110 section 14.2. Ranges that start past the end are
+// dropped; if none is left the range is unsatisfiable. Any other
+// problem gives errInvalidRange, and the header should be ignored.
+func parseRange(header string, size int64) ([]byteRange, error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errInvalidRange, header)
+	}
+	var ranges []byteRange
+	count := 0
+	for part := range strings.SplitSeq(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if count++; count > maxRanges {
+			return nil, fmt.Errorf("%w: more than %d ranges", errInvalidRange, maxRanges)
+		}
+		first, last, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", errInvalidRange, part)
+		}
+
+		var r byteRange
+		if first == "" {
+			// suffix range: the last n bytes
+			n, err := parseRangeNumber(last)
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				continue
+			}
+			n = min(n, size)
+			r = byteRange{start: size - n, length: n}
+		} else {
+			start, err := parseRangeNumber(first)
+			if err != nil {
+				return nil, err
+			}
+			end := size - 1
+			if last != "" {
+				if end, err = parseRangeNumber(last); err != nil {
+					return nil, err
+				}
+				if end < start {
+					return nil, fmt.Errorf("%w: %q", errInvalidRange, part)
+				}
+				end = min(end, size-1)
+			}
+			r = byteRange{start: start, length: end - start + 1}
+		}
+		if r.start >= size || r.length <= 0 {
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("%w: no ranges in %q", errInvalidRange, header)
+	}
+	if len(ranges) == 0 {
+		return nil, errUnsatisfiableRange
+	}
+	return ranges, nil
+}
+
+func parseRangeNumber(s string) (int64, error) {
+	if s == "" || strings.Trim(s, "0123456789") != "" {
+		return 0, fmt.Errorf("%w: bad position %q", errInvalidRange, s)
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: bad position %q", errInvalidRange, s)
+	}
+	return n, nil
+}
+
+// multipartRanges lays out a multipart/byteranges body for ranges of
+// file. The length is worked out up front so the response can carry a
+// Content-Length, and the parts are read from the file as they are sent.
+func multipartRanges(file io.ReaderAt, ranges []byteRange, contentType string, size int64) (io.Reader, int64, string) {
+	boundary := rand.Text()
+	var readers []io.Reader
+	var length int64
+	for i, r := range ranges {
+		partHeader := fmt.Sprintf("--%s\r\nContent-Type: %s\r\nContent-Range: %s\r\n\r\n", boundary, contentType, r.contentRange(size))
+		if i > 0 {
+			partHeader = "\r\n" + partHeader
+		}
+		readers = append(readers, strings.NewReader(partHeader), io.NewSectionReader(file, r.start, r.length))
+		length += int64(len(partHeader)) + r.length
+	}
+	closing := "\r\n--" + boundary + "--\r\n"
+	readers = append(readers, strings.NewReader(closing))
+	length += int64(len(closing))
+	return io.MultiReader(readers...), length, boundary
+}
@@ -0,0 +1,88 @@
+package static
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"path"
+	"unicode/utf8"
+)
+
+// sniffLen is how much of a file detectContentType looks at, the same
+// as browsers do.
+const sniffLen = 512
+
+// detectContentType goes by the file extension, and failing that by the
+// first bytes of the file. The file is left at its start either way.
+func detectContentType(name string, file io.ReadSeeker) (string, error) {
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType, nil
+	}
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return sniff(buf[:n]), nil
+}
+
+// signatures are the magic numbers of the binary formats we are most
+// likely to serve, checked in order.
+var signatures = []struct {
+	offset      int
+	magic       string
+	contentType string
+}{
+	{0, "%PDF-", "application/pdf"},
+	{0, "\x89PNG\r\n\x1a\n", "image/png"},
+	{0, "\xff\xd8\xff", "image/jpeg"},
+	{0, "GIF87a", "image/gif"},
+	{0, "GIF89a", "image/gif"},
+	{8, "WEBP", "image/webp"},
+	{4, "ftyp", "video/mp4"},
+	{0, "\x1a\x45\xdf\xa3", "video/webm"},
+	{0, "ID3", "audio/mpeg"},
+	{0, "OggS", "application/ogg"},
+	{0, "PK\x03\x04", "application/zip"},
+	{0, "\x1f\x8b\x08", "application/gzip"},
+	{0, "\x00asm", "application/wasm"},
+}
+
+// sniff guesses a content type from the start of a file: a known
+// signature, then HTML or other text, then binary.
+func sniff(data []byte) string {
+	for _, sig := range signatures {
+		if bytes.HasPrefix(data[min(sig.offset, len(data)):], []byte(sig.magic)) {
+			return sig.contentType
+		}
+	}
+	trimmed := bytes.ToLower(bytes.TrimLeft(data, " \t\r\n"))
+	for _, prefix := range []string{"<!doctype html", "<html", "<head", "<body"} {
+		if bytes.HasPrefix(trimmed, []byte(prefix)) {
+			return "text/html; charset=utf-8"
+		}
+	}
+	if isText(data) {
+		return "text/plain; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// isText allows UTF-8 without control characters other than whitespace.
+// The last few bytes may be a rune cut off by the sniff length.
+func isText(data []byte) bool {
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			return len(data) == sniffLen && len(data)-i < utf8.UTFMax
+		}
+		if r < ' ' && r != '\t' && r != '\n' && r != '\r' && r != '\f' || r == 0x7f {
+			return false
+		}
+		i += size
+	}
+	return true
+}
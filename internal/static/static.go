@@ -0,0 +1,274 @@
+package static
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"MyOwnHTTP/internal/headers"
+	"MyOwnHTTP/internal/request"
+	"MyOwnHTTP/internal/response"
+	"MyOwnHTTP/internal/server"
+)
+
+// indexFile is served in place of a directory that has one.
+const indexFile = "index.html"
+
+// FileServer serves the files under a directory. Requests can't reach
+// outside of it, whether through ".." or symlinks.
+type FileServer struct {
+	dir string
+	// ListDirectories answers requests for directories without an
+	// index.html with a listing of their entries instead of a 404.
+	ListDirectories bool
+}
+
+func New(dir string) *FileServer {
+	return &FileServer{dir: dir}
+}
+
+// Handler serves the file named by the request. Under a router pattern
+// ending in * that is the part of the path the * matched, otherwise the
+// whole path. Only GET and HEAD are allowed.
+func (f *FileServer) Handler() server.Handler {
+	return server.HandleErrors(func(w *response.Writer, req *request.Request) *server.HandlerError {
+		name, ok := req.Params["*"]
+		if !ok {
+			name = req.URL.Path
+		}
+		return f.serve(w, req, name, true)
+	})
+}
+
+// File serves the one named file whatever the request path is.
+func (f *FileServer) File(name string) server.Handler {
+	return server.HandleErrors(func(w *response.Writer, req *request.Request) *server.HandlerError {
+		return f.serve(w, req, name, false)
+	})
+}
+
+func (f *FileServer) serve(w *response.Writer, req *request.Request, name string, allowDir bool) *server.HandlerError {
+	if method := req.RequestLine.Method; method != "GET" && method != "HEAD" {
+		h := headers.NewHeaders()
+		h.Set("Allow", "GET, HEAD")
+		return writeError(w, response.StatusMethodNotAllowed, h)
+	}
+
+	// the root keeps symlinks and ".." from leading out of the directory
+	root, err := os.OpenRoot(f.dir)
+	if err != nil {
+		return openError(err)
+	}
+	defer root.Close()
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		name = "."
+	}
+	if !filepath.IsLocal(name) {
+		return openError(fs.ErrNotExist)
+	}
+	file, err := root.Open(name)
+	if err != nil {
+		return openError(err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return openError(err)
+	}
+
+	if info.IsDir() {
+		if !allowDir {
+			return openError(fs.ErrNotExist)
+		}
+		if !strings.HasSuffix(req.URL.Path, "/") {
+			return redirectToDir(w, req)
+		}
+		index, err := root.Open(path.Join(name, indexFile))
+		if err == nil {
+			defer index.Close()
+			if indexInfo, err := index.Stat(); err == nil && indexInfo.Mode().IsRegular() {
+				return serveContent(w, req, index, indexInfo)
+			}
+		}
+		if !f.ListDirectories {
+			return openError(fs.ErrNotExist)
+		}
+		return listDirectory(w, req, file)
+	}
+	if !info.Mode().IsRegular() {
+		return openError(fs.ErrNotExist)
+	}
+	return serveContent(w, req, file, info)
+}
+
+// serveContent answers with the file, or the part of it the request
+// asked for, once the conditional headers have had their say.
+func serveContent(w *response.Writer, req *request.Request, file *os.File, info fs.FileInfo) *server.HandlerError {
+	size := info.Size()
+	etag := fileETag(info)
+	modTime := info.ModTime()
+
+	h := headers.NewHeaders()
+	h.Set("ETag", etag)
+	h.Set("Last-Modified", formatHTTPDate(modTime))
+	h.Set("Accept-Ranges", "bytes")
+	switch checkPreconditions(req, etag, modTime) {
+	case preconditionFailed:
+		return writeError(w, response.StatusPreconditionFailed, h)
+	case notModified:
+		// RFC 9110 section 15.4.5: no content headers on a 304
+		w.WriteStatusLine(response.StatusNotModified)
+		w.WriteHeaders(h)
+		// an empty body still marks the response done, so the
+		// connection is kept
+		w.WriteBody(nil)
+		return nil
+	}
+
+	contentType, err := detectContentType(info.Name(), file)
+	if err != nil {
+		return readError(err)
+	}
+
+	var ranges []byteRange
+	if rangeHeader, err := req.Headers.Get("Range"); err == nil && rangeApplies(req, etag, modTime) {
+		ranges, err = parseRange(rangeHeader, size)
+		if errors.Is(err, errUnsatisfiableRange) {
+			h.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			return writeError(w, response.StatusRangeNotSatisfiable, h)
+		}
+		// any other problem with the header means it is ignored and the
+		// whole file is sent
+	}
+
+	statusCode := response.StatusOK
+	var body io.Reader
+	var length int64
+	switch len(ranges) {
+	case 0:
+		h.Set("Content-Type", contentType)
+		body, length = file, size
+	case 1:
+		statusCode = response.StatusPartialContent
+		h.Set("Content-Type", contentType)
+		h.Set("Content-Range", ranges[0].contentRange(size))
+		body = io.NewSectionReader(file, ranges[0].start, ranges[0].length)
+		length = ranges[0].length
+	default:
+		statusCode = response.StatusPartialContent
+		var boundary string
+		body, length, boundary = multipartRanges(file, ranges, contentType, size)
+		h.Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+	}
+	h.Set("Content-Length", strconv.FormatInt(length, 10))
+
+	w.WriteStatusLine(statusCode)
+	w.WriteHeaders(h)
+	if req.RequestLine.Method == "HEAD" {
+		w.WriteBody(nil)
+		return nil
+	}
+	if _, err := w.WriteBodyFrom(body); err != nil {
+		// too late for an error response, the client sees a short body
+		return readError(err)
+	}
+	return nil
+}
+
+// redirectToDir sends a request for a directory without a trailing
+// slash to the same path with one, so relative links in it work.
+func redirectToDir(w *response.Writer, req *request.Request) *server.HandlerError {
+	// browsers take //host and /\host as links to another host, so the
+	// path has to start with exactly one slash
+	location := "/" + strings.TrimLeft(req.URL.RawPath, "/\\") + "/"
+	if req.URL.RawQuery != "" {
+		location += "?" + req.URL.RawQuery
+	}
+	h := headers.NewHeaders()
+	h.Set("Location", location)
+	return writeError(w, response.StatusMovedPermanently, h)
+}
+
+func listDirectory(w *response.Writer, req *request.Request, dir *os.File) *server.HandlerError {
+	entries, err := dir.ReadDir(-1)
+	if err != nil {
+		return readError(err)
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	var b strings.Builder
+	title := html.EscapeString(req.URL.Path)
+	fmt.Fprintf(&b, "<html>\n<head>\n<title>Index of %s</title>\n</head>\n<body>\n<h1>Index of %s</h1>\n<ul>\n", title, title)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", html.EscapeString((&url.URL{Path: name}).String()), html.EscapeString(name))
+	}
+	b.WriteString("</ul>\n</body>\n</html>\n")
+
+	body := []byte(b.String())
+	h := response.GetDefaultHeaders(len(body))
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteStatusLine(response.StatusOK)
+	w.WriteHeaders(h)
+	if req.RequestLine.Method == "HEAD" {
+		body = nil
+	}
+	w.WriteBody(body)
+	return nil
+}
+
+// writeError answers with a bare status and the reason phrase as body,
+// for the statuses that carry headers a HandlerError can't.
+func writeError(w *response.Writer, statusCode response.StatusCode, h *headers.Headers) *server.HandlerError {
+	body := []byte(response.StatusText(statusCode) + "\n")
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	h.Set("Content-Type", "text/plain")
+	w.WriteStatusLine(statusCode)
+	w.WriteHeaders(h)
+	w.WriteBody(body)
+	return nil
+}
+
+// openError hides why a file couldn't be opened from the client, beyond
+// whether it is missing or off limits.
+func openError(err error) *server.HandlerError {
+	var pathErr *fs.PathError
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return &server.HandlerError{StatusCode: response.StatusForbidden, Message: "Access denied"}
+	case errors.Is(err, fs.ErrNotExist):
+		return &server.HandlerError{StatusCode: response.StatusNotFound, Message: "File not found"}
+	case errors.As(err, &pathErr):
+		// whatever else the root refuses to open, like a symlink leading
+		// out of it, is as good as missing to the client
+		log.Printf("Refused to open %q: %v\n", pathErr.Path, err)
+		return &server.HandlerError{StatusCode: response.StatusNotFound, Message: "File not found"}
+	}
+	return readError(err)
+}
+
+// readError logs the failure and tells the client no more than that it
+// happened, since OS errors name paths on the server.
+func readError(err error) *server.HandlerError {
+	log.Printf("Failed to read file: %v\n", err)
+	return &server.HandlerError{
+		StatusCode: response.StatusInternalServerError,
+		Message:    "Failed to read file",
+	}
+}
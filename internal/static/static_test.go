@@ -0,0 +1,358 @@
+package static
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"MyOwnHTTP/internal/request"
+	"MyOwnHTTP/internal/response"
+	"MyOwnHTTP/internal/router"
+	"MyOwnHTTP/internal/server"
+)
+
+const testContent = "0123456789abcdefghij"
+
+// testDir lays out a site with a file, a directory with an index, a
+// directory without one and a symlink pointing outside.
+func testDir(t *testing.T) string {
+	t.Helper()
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644))
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data.txt"), []byte(testContent), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "noext"), []byte("%PDF-1.7 ..."), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "site"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "site", "index.html"), []byte("<h1>home</h1>"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "files"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "files", "a <b>.txt"), []byte("a"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "files", "sub"), 0o755))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(dir, "escape.txt")))
+
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "data.txt"), modTime, modTime))
+	return dir
+}
+
+func serve(t *testing.T, handler server.Handler, method, target string, extra ...string) (*http.Response, string) {
+	t.Helper()
+	raw := method + " " + target + " HTTP/1.1\r\nHost: localhost\r\n"
+	for _, line := range extra {
+		raw += line + "\r\n"
+	}
+	req, err := request.RequestFromReader(strings.NewReader(raw + "\r\n"))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	w := response.NewWriter(&buf)
+	w.KeepAlive = true
+	handler(w, req)
+	resp, err := http.ReadResponse(bufio.NewReader(&buf), &http.Request{Method: method})
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestFileServer(t *testing.T) {
+	files := New(testDir(t))
+	handler := files.Handler()
+
+	// Test: Plain file with validators
+	resp, body := serve(t, handler, "GET", "/data.txt")
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, testContent, body)
+	assert.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "20", resp.Header.Get("Content-Length"))
+	assert.Equal(t, "Wed, 01 May 2024 12:00:00 GMT", resp.Header.Get("Last-Modified"))
+	assert.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+	etag := resp.Header.Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]+-14"$`, etag)
+
+	// Test: HEAD has the headers but no body
+	resp, body = serve(t, handler, "HEAD", "/data.txt")
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "20", resp.Header.Get("Content-Length"))
+	assert.Empty(t, body)
+	assert.False(t, resp.Close)
+
+	// Test: Content sniffed when the extension says nothing
+	resp, _ = serve(t, handler, "GET", "/noext")
+	assert.Equal(t, "application/pdf", resp.Header.Get("Content-Type"))
+
+	// Test: index.html stands in for its directory
+	resp, body = serve(t, handler, "GET", "/site/")
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "<h1>home</h1>", body)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	// Test: Directories get their trailing slash
+	resp, _ = serve(t, handler, "GET", "/site?x=1")
+	assert.Equal(t, 301, resp.StatusCode)
+	assert.Equal(t, "/site/?x=1", resp.Header.Get("Location"))
+
+	// Test: Extra leading slashes don't make the redirect point off site
+	resp, _ = serve(t, handler, "GET", "//site")
+	assert.Equal(t, 301, resp.StatusCode)
+	assert.Equal(t, "/site/", resp.Header.Get("Location"))
+
+	// Test: No listing unless asked for
+	resp, _ = serve(t, handler, "GET", "/files/")
+	assert.Equal(t, 404, resp.StatusCode)
+	files.ListDirectories = true
+	resp, body = serve(t, handler, "GET", "/files/")
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, body, `<a href="a%20%3Cb%3E.txt">a &lt;b&gt;.txt</a>`)
+	assert.Contains(t, body, `<a href="sub/">sub/</a>`)
+
+	// Test: Nothing outside the directory can be reached
+	for _, target := range []string{"/../../etc/passwd", "/%2e%2e/%2e%2e/etc/passwd", "/escape.txt", "/missing"} {
+		resp, body = serve(t, handler, "GET", target)
+		assert.Equal(t, 404, resp.StatusCode, target)
+		assert.NotContains(t, body, "secret", target)
+	}
+
+	// Test: Only GET and HEAD
+	resp, _ = serve(t, handler, "POST", "/data.txt")
+	assert.Equal(t, 405, resp.StatusCode)
+	assert.Equal(t, "GET, HEAD", resp.Header.Get("Allow"))
+
+	// Test: Mounted under a router wildcard
+	r := router.New()
+	r.Get("/assets/*", handler)
+	_, body = serve(t, r.Handler(), "GET", "/assets/data.txt")
+	assert.Equal(t, testContent, body)
+
+	// Test: A single file
+	_, body = serve(t, files.File("data.txt"), "GET", "/anything")
+	assert.Equal(t, testContent, body)
+	resp, _ = serve(t, files.File("site"), "GET", "/anything")
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestConditionalRequests(t *testing.T) {
+	handler := New(testDir(t)).Handler()
+	resp, _ := serve(t, handler, "GET", "/data.txt")
+	etag := resp.Header.Get("ETag")
+
+	cases := []struct {
+		name       string
+		header     string
+		statusCode int
+	}{
+		{"if-none-match", "If-None-Match: " + etag, 304},
+		{"if-none-match weak", "If-None-Match: \"other\", W/" + etag, 304},
+		{"if-none-match star", "If-None-Match: *", 304},
+		{"if-none-match changed", "If-None-Match: \"other\"", 200},
+		{"if-modified-since same", "If-Modified-Since: Wed, 01 May 2024 12:00:00 GMT", 304},
+		{"if-modified-since later", "If-Modified-Since: Thu, 02 May 2024 12:00:00 GMT", 304},
+		{"if-modified-since earlier", "If-Modified-Since: Tue, 30 Apr 2024 12:00:00 GMT", 200},
+		{"if-modified-since bad date", "If-Modified-Since: yesterday", 200},
+		{"if-match", "If-Match: " + etag, 200},
+		{"if-match changed", "If-Match: \"other\"", 412},
+		{"if-match weak", "If-Match: W/" + etag, 412},
+		{"if-unmodified-since earlier", "If-Unmodified-Since: Tue, 30 Apr 2024 12:00:00 GMT", 412},
+		{"if-unmodified-since same", "If-Unmodified-Since: Wed, 01 May 2024 12:00:00 GMT", 200},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp, body := serve(t, handler, "GET", "/data.txt", c.header)
+			assert.Equal(t, c.statusCode, resp.StatusCode)
+			if c.statusCode == 304 {
+				assert.Empty(t, body)
+				assert.Equal(t, etag, resp.Header.Get("ETag"))
+				assert.Empty(t, resp.Header.Get("Content-Length"))
+				// Test: No body and no length, yet the connection stays up
+				assert.False(t, resp.Close)
+			}
+		})
+	}
+
+	// Test: If-None-Match wins over If-Modified-Since
+	resp, _ = serve(t, handler, "GET", "/data.txt", "If-None-Match: \"other\"", "If-Modified-Since: Thu, 02 May 2024 12:00:00 GMT")
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+func TestRangeRequests(t *testing.T) {
+	handler := New(testDir(t)).Handler()
+	resp, _ := serve(t, handler, "GET", "/data.txt")
+	etag := resp.Header.Get("ETag")
+
+	// Test: Single range
+	resp, body := serve(t, handler, "GET", "/data.txt", "Range: bytes=2-5")
+	assert.Equal(t, 206, resp.StatusCode)
+	assert.Equal(t, "2345", body)
+	assert.Equal(t, "bytes 2-5/20", resp.Header.Get("Content-Range"))
+	assert.Equal(t, "4", resp.Header.Get("Content-Length"))
+
+	// Test: Suffix range
+	_, body = serve(t, handler, "GET", "/data.txt", "Range: bytes=-3")
+	assert.Equal(t, "hij", body)
+
+	// Test: Several ranges as multipart/byteranges
+	resp, body = serve(t, handler, "GET", "/data.txt", "Range: bytes=0-1, 18-")
+	assert.Equal(t, 206, resp.StatusCode)
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/byteranges", mediaType)
+	assert.Equal(t, int64(len(body)), resp.ContentLength)
+	mr := multipart.NewReader(strings.NewReader(body), params["boundary"])
+	for _, want := range []struct{ contentRange, data string }{{"bytes 0-1/20", "01"}, {"bytes 18-19/20", "ij"}} {
+		part, err := mr.NextPart()
+		require.NoError(t, err)
+		assert.Equal(t, want.contentRange, part.Header.Get("Content-Range"))
+		assert.Equal(t, "text/plain; charset=utf-8", part.Header.Get("Content-Type"))
+		data, err := io.ReadAll(part)
+		require.NoError(t, err)
+		assert.Equal(t, want.data, string(data))
+	}
+	_, err = mr.NextPart()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Test: Unsatisfiable range
+	resp, _ = serve(t, handler, "GET", "/data.txt", "Range: bytes=20-30")
+	assert.Equal(t, 416, resp.StatusCode)
+	assert.Equal(t, "bytes */20", resp.Header.Get("Content-Range"))
+
+	// Test: Malformed range is ignored
+	resp, body = serve(t, handler, "GET", "/data.txt", "Range: lines=1-2")
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, testContent, body)
+
+	// Test: If-Range with the current validator allows the range
+	resp, _ = serve(t, handler, "GET", "/data.txt", "Range: bytes=0-0", "If-Range: "+etag)
+	assert.Equal(t, 206, resp.StatusCode)
+	resp, _ = serve(t, handler, "GET", "/data.txt", "Range: bytes=0-0", "If-Range: Wed, 01 May 2024 12:00:00 GMT")
+	assert.Equal(t, 206, resp.StatusCode)
+
+	// Test: If-Range with a stale validator gets the whole file
+	resp, body = serve(t, handler, "GET", "/data.txt", "Range: bytes=0-0", "If-Range: \"stale\"")
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, testContent, body)
+}
+
+func TestParseRange(t *testing.T) {
+	cases := []struct {
+		header string
+		ranges []byteRange
+		err    error
+	}{
+		{"bytes=0-0", []byteRange{{0, 1}}, nil},
+		{"bytes=5-", []byteRange{{5, 5}}, nil},
+		{"bytes=-4", []byteRange{{6, 4}}, nil},
+		{"bytes=-40", []byteRange{{0, 10}}, nil},
+		{"bytes=8-100", []byteRange{{8, 2}}, nil},
+		{"bytes= 1-2 , ,4-5", []byteRange{{1, 2}, {4, 2}}, nil},
+		{"bytes=1-2,20-30", []byteRange{{1, 2}}, nil},
+		{"bytes=10-", nil, errUnsatisfiableRange},
+		{"bytes=-0", nil, errUnsatisfiableRange},
+		{"bytes=3-2", nil, errInvalidRange},
+		{"bytes=a-b", nil, errInvalidRange},
+		{"bytes=+1-2", nil, errInvalidRange},
+		{"bytes=1", nil, errInvalidRange},
+		{"bytes=", nil, errInvalidRange},
+		{"items=0-1", nil, errInvalidRange},
+		{"bytes=99999999999999999999-", nil, errInvalidRange},
+		{"bytes=" + strings.Repeat("0-0,", maxRanges+1), nil, errInvalidRange},
+	}
+	for _, c := range cases {
+		t.Run(c.header, func(t *testing.T) {
+			ranges, err := parseRange(c.header, 10)
+			if c.err != nil {
+				assert.ErrorIs(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.ranges, ranges)
+		})
+	}
+}
+
+func TestSniff(t *testing.T) {
+	cases := []struct {
+		data        string
+		contentType string
+	}{
+		{"\x89PNG\r\n\x1a\n....", "image/png"},
+		{"\x00\x00\x00\x18ftypmp42", "video/mp4"},
+		{"RIFF....WEBPVP8 ", "image/webp"},
+		{"  <!DOCTYPE html><html>", "text/html; charset=utf-8"},
+		{"plain text, café\n", "text/plain; charset=utf-8"},
+		{"", "text/plain; charset=utf-8"},
+		{"\x00\x01\x02binary", "application/octet-stream"},
+		{"caf\xe9", "application/octet-stream"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.contentType, sniff([]byte(c.data)), c.data)
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	s, err := server.ServeWithOptions(New(testDir(t)).Handler(), server.Options{})
+	require.NoError(t, err)
+	defer s.Close()
+	conn, err := net.Dial("tcp", s.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// Test: Responses without a body leave the connection open
+	for _, method := range []string{"HEAD", "GET"} {
+		_, err = conn.Write([]byte(method + " /data.txt HTTP/1.1\r\nHost: localhost\r\nIf-None-Match: *\r\n\r\n"))
+		require.NoError(t, err)
+		resp, err := http.ReadResponse(r, &http.Request{Method: method})
+		require.NoError(t, err)
+		assert.Equal(t, 304, resp.StatusCode)
+		assert.False(t, resp.Close)
+	}
+	_, err = conn.Write([]byte("HEAD /data.txt HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	resp, err := http.ReadResponse(r, &http.Request{Method: "HEAD"})
+	require.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	_, err = conn.Write([]byte("GET /data.txt HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	resp, err = http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, testContent, string(body))
+
+	// Test: Error responses to HEAD have no body either
+	cases := []struct {
+		target     string
+		extra      string
+		statusCode int
+	}{
+		{"/missing", "", 404},
+		{"/site", "", 301},
+		{"/data.txt", "If-Match: \"nope\"\r\n", 412},
+		{"/data.txt", "Range: bytes=100-\r\n", 416},
+	}
+	for _, c := range cases {
+		_, err = conn.Write([]byte("HEAD " + c.target + " HTTP/1.1\r\nHost: localhost\r\n" + c.extra + "\r\n" +
+			"GET /data.txt HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+		require.NoError(t, err)
+		resp, err = http.ReadResponse(r, &http.Request{Method: "HEAD"})
+		require.NoError(t, err)
+		assert.Equal(t, c.statusCode, resp.StatusCode, c.target)
+		assert.False(t, resp.Close, c.target)
+		resp, err = http.ReadResponse(r, nil)
+		require.NoError(t, err)
+		body, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, testContent, string(body), c.target)
+	}
+}
@@ -0,0 +1,113 @@
+package vhost
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"MyOwnHTTP/internal/request"
+	"MyOwnHTTP/internal/response"
+	"MyOwnHTTP/internal/server"
+)
+
+// Hosts picks a handler by the host a request is for, so several sites
+// can share one server.
+type Hosts struct {
+	exact     map[string]server.Handler
+	wildcards map[string]server.Handler
+	// Default runs for hosts nothing else matches. Without it those get
+	// 421 Misdirected Request.
+	Default server.Handler
+}
+
+func New() *Hosts {
+	return &Hosts{
+		exact:     map[string]server.Handler{},
+		wildcards: map[string]server.Handler{},
+	}
+}
+
+// Handler returns the dispatcher as a server.Handler.
+func (h *Hosts) Handler() server.Handler {
+	return h.serve
+}
+
+// Handle registers a handler for a host name like tools.example.com, or
+// for every subdomain with *.example.com. Exact names win over
+// wildcards and longer wildcards over shorter ones; a wildcard doesn't
+// match the bare domain. Ports are ignored. It panics on malformed
+// patterns since those are programming errors.
+func (h *Hosts) Handle(pattern string, handler server.Handler) {
+	name := normalize(pattern)
+	if suffix, ok := strings.CutPrefix(name, "*."); ok {
+		if !isHostName(suffix) {
+			panic(fmt.Errorf("vhost: bad wildcard pattern %q", pattern))
+		}
+		h.wildcards[suffix] = handler
+		return
+	}
+	if !isHostName(name) {
+		panic(fmt.Errorf("vhost: bad host pattern %q", pattern))
+	}
+	h.exact[name] = handler
+}
+
+func (h *Hosts) serve(w *response.Writer, req *request.Request) {
+	if handler := h.lookup(normalize(hostname(req.Host))); handler != nil {
+		handler(w, req)
+		return
+	}
+	if h.Default != nil {
+		h.Default(w, req)
+		return
+	}
+	body := []byte(response.StatusText(response.StatusMisdirectedRequest) + "\n")
+	headers := response.GetDefaultHeaders(len(body))
+	headers.Set("Content-Type", "text/plain")
+	w.WriteStatusLine(response.StatusMisdirectedRequest)
+	w.WriteHeaders(headers)
+	w.WriteBody(body)
+}
+
+func (h *Hosts) lookup(name string) server.Handler {
+	if handler, ok := h.exact[name]; ok {
+		return handler
+	}
+	// walk up from the most specific parent domain
+	for rest := name; ; {
+		_, parent, ok := strings.Cut(rest, ".")
+		if !ok {
+			return nil
+		}
+		if handler, ok := h.wildcards[parent]; ok {
+			return handler
+		}
+		rest = parent
+	}
+}
+
+// hostname drops the port from a Host value, if there is one.
+func hostname(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		return name
+	}
+	return strings.Trim(host, "[]")
+}
+
+// normalize lower-cases a host name and drops the trailing dot of a
+// fully qualified one.
+func normalize(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+func isHostName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for label := range strings.SplitSeq(name, ".") {
+		if label == "" || strings.ContainsAny(label, "*/:@ ") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package vhost
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"MyOwnHTTP/internal/request"
+	"MyOwnHTTP/internal/response"
+)
+
+func reply(name string) func(w *response.Writer, req *request.Request) {
+	return func(w *response.Writer, req *request.Request) {
+		w.WriteStatusLine(response.StatusOK)
+		w.WriteHeaders(response.GetDefaultHeaders(len(name)))
+		w.WriteBody([]byte(name))
+	}
+}
+
+func do(t *testing.T, h *Hosts, target, host string) (*http.Response, string) {
+	t.Helper()
+	req, err := request.RequestFromReader(strings.NewReader("GET " + target + " HTTP/1.1\r\nHost: " + host + "\r\n\r\n"))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	h.Handler()(response.NewWriter(&buf), req)
+	resp, err := http.ReadResponse(bufio.NewReader(&buf), nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestHosts(t *testing.T) {
+	h := New()
+	h.Handle("grafana.internal", reply("grafana"))
+	h.Handle("*.internal", reply("any internal"))
+	h.Handle("*.ci.internal", reply("ci"))
+	h.Handle("Wiki.Example.com.", reply("wiki"))
+
+	cases := []struct {
+		target string
+		host   string
+		status int
+		body   string
+	}{
+		{"/", "grafana.internal", 200, "grafana"},
+		{"/", "GRAFANA.internal:8080", 200, "grafana"},
+		{"/", "grafana.internal.", 200, "grafana"},
+		{"/", "jenkins.internal", 200, "any internal"},
+		{"/", "a.b.internal", 200, "any internal"},
+		{"/", "runner-1.ci.internal", 200, "ci"},
+		{"/", "x.runner-1.ci.internal", 200, "ci"},
+		{"/", "ci.internal", 200, "any internal"},
+		{"/", "wiki.example.com", 200, "wiki"},
+		// Test: Wildcards don't cover the bare domain
+		{"/", "internal", 421, "Misdirected Request\n"},
+		{"/", "example.com", 421, "Misdirected Request\n"},
+		{"/", "[::1]:8080", 421, "Misdirected Request\n"},
+		// Test: Absolute-form targets pick the host over the header
+		{"http://grafana.internal/d", "jenkins.internal", 200, "grafana"},
+	}
+	for _, c := range cases {
+		t.Run(c.host+c.target, func(t *testing.T) {
+			resp, body := do(t, h, c.target, c.host)
+			assert.Equal(t, c.status, resp.StatusCode)
+			assert.Equal(t, c.body, body)
+		})
+	}
+
+	// Test: Default handler for unknown hosts
+	h.Default = reply("default")
+	_, body := do(t, h, "/", "example.com")
+	assert.Equal(t, "default", body)
+}
+
+func TestBadPatterns(t *testing.T) {
+	for _, pattern := range []string{"", "*", "*.", "a..b", "a.*.b", "**.a", "a/b", "a:80"} {
+		assert.Panics(t, func() { New().Handle(pattern, reply("x")) }, pattern)
+	}
+}